@@ -1,6 +1,7 @@
 package concurrency
 
 import (
+	"context"
 	"github.com/koleter/go-util/concurrency/lock"
 	"github.com/koleter/go-util/queue"
 )
@@ -23,6 +24,10 @@ func (c *ConcurrentDeque[T]) WithLock(f func()) {
 	f()
 }
 
+func (c *ConcurrentDeque[T]) WithLockContext(ctx context.Context, f func()) error {
+	return c.lock.WithLockContext(ctx, f)
+}
+
 func (c *ConcurrentDeque[T]) IsEmpty() bool {
 	return c.deque.IsEmpty()
 }
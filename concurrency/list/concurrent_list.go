@@ -1,6 +1,7 @@
 package list
 
 import (
+	"context"
 	"github.com/koleter/go-util/concurrency/lock"
 	"sort"
 )
@@ -23,6 +24,14 @@ func (tsl *ThreadSafeList[T]) WithLock(f func()) {
 	f()
 }
 
+var _ lock.ContextLocker = (*ThreadSafeList[int])(nil)
+
+// WithLockContext 持有锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err();
+// ConcurrentList 只要求 lock.Locker, 需要时通过类型断言 lock.ContextLocker 使用
+func (tsl *ThreadSafeList[T]) WithLockContext(ctx context.Context, f func()) error {
+	return tsl.lock.WithLockContext(ctx, f)
+}
+
 func (tsl *ThreadSafeList[T]) Append(element ...T) {
 	tsl.lock.Lock()
 	defer tsl.lock.Unlock()
@@ -1,6 +1,8 @@
 package list
 
 import (
+	"context"
+	"github.com/koleter/go-util/concurrency/lock"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"testing"
@@ -158,3 +160,12 @@ func TestThreadSafeList_AliasType(t *testing.T) {
 	assert.Equal(t, 1, safeList.Len())
 	assert.Equal(t, 2, safeList.Get(0))
 }
+
+func Test_threadSafeList_WithLockContext_type_assertion(t *testing.T) {
+	var safeList ConcurrentList[int] = NewThreadSafeList([]int{})
+	locker, ok := safeList.(lock.ContextLocker)
+	assert.True(t, ok)
+	called := false
+	assert.NoError(t, locker.WithLockContext(context.Background(), func() { called = true }))
+	assert.True(t, called)
+}
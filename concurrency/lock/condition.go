@@ -0,0 +1,122 @@
+package lock
+
+import (
+	"context"
+	"github.com/koleter/go-util/g"
+	"github.com/koleter/go-util/list/dlinkedlist"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Condition 绑定在 ReentrantMutex 上的条件变量, 类似 java 的 Condition
+//
+// 等待时会完全释放当前协程对锁的所有嵌套持有, 被唤醒后重新获取锁并恢复原来的嵌套深度
+type Condition struct {
+	lock *ReentrantMutex
+
+	mu      sync.Mutex // 保护 waiters
+	waiters dlinkedlist.DoublyLinkedList[*condWaiter]
+}
+
+// condWaiter 等待条件的协程
+type condWaiter struct {
+	ready chan struct{}
+	node  *dlinkedlist.Node[*condWaiter]
+}
+
+// NewCondition 创建绑定在当前锁上的条件变量
+func (r *ReentrantMutex) NewCondition() *Condition {
+	return &Condition{lock: r}
+}
+
+// Wait 释放锁并等待被唤醒, 返回前重新获取锁, 调用前当前协程必须持有锁
+func (c *Condition) Wait() {
+	c.wait(nil)
+}
+
+// WaitTimeout 释放锁并等待被唤醒, 超时返回false, 返回前重新获取锁
+func (c *Condition) WaitTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return c.wait(ctx.Done())
+}
+
+// WaitContext 释放锁并等待被唤醒, ctx结束时返回ctx.Err(), 返回前重新获取锁
+func (c *Condition) WaitContext(ctx context.Context) error {
+	if !c.wait(ctx.Done()) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Signal 唤醒一个等待最久的协程
+func (c *Condition) Signal() {
+	c.mu.Lock()
+	w := c.dequeue()
+	c.mu.Unlock()
+	if w != nil {
+		w.ready <- struct{}{}
+	}
+}
+
+// SignalAll 唤醒所有等待的协程
+func (c *Condition) SignalAll() {
+	c.mu.Lock()
+	var ws []*condWaiter
+	for w := c.dequeue(); w != nil; w = c.dequeue() {
+		ws = append(ws, w)
+	}
+	c.mu.Unlock()
+	for _, w := range ws {
+		w.ready <- struct{}{}
+	}
+}
+
+// wait 等待被唤醒, done关闭时放弃等待并返回false
+func (c *Condition) wait(done <-chan struct{}) bool {
+	saved := c.lock.HoldCount()
+	if saved == 0 {
+		panic("wait on condition without holding reentrant mutex")
+	}
+	w := &condWaiter{ready: make(chan struct{}, 1)}
+	c.mu.Lock()
+	w.node = c.waiters.PushBack(w)
+	c.mu.Unlock()
+
+	// 完全释放锁, 醒来后恢复嵌套深度
+	atomic.StoreInt32(&c.lock.count, 0)
+	afterUnlock(c.lock, g.G())
+	c.lock.release()
+	defer func() {
+		c.lock.Lock()
+		atomic.StoreInt32(&c.lock.count, int32(saved))
+	}()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if w.node == nil {
+			// 超时的同时已经被唤醒, 视为被唤醒, 避免丢失信号
+			return true
+		}
+		c.waiters.Remove(w.node)
+		w.node = nil
+		return false
+	}
+}
+
+// dequeue 取出等待最久的协程, 调用前需要持有 c.mu
+func (c *Condition) dequeue() *condWaiter {
+	head := c.waiters.Head()
+	if head == nil {
+		return nil
+	}
+	w := head.Value
+	c.waiters.Remove(head)
+	w.node = nil
+	return w
+}
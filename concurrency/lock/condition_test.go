@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingQueue 使用 Condition 实现的有界阻塞队列
+type blockingQueue struct {
+	lock     *ReentrantMutex
+	notEmpty *Condition
+	notFull  *Condition
+	items    []int
+	capacity int
+}
+
+func newBlockingQueue(capacity int) *blockingQueue {
+	l := new(ReentrantMutex)
+	return &blockingQueue{
+		lock:     l,
+		notEmpty: l.NewCondition(),
+		notFull:  l.NewCondition(),
+		capacity: capacity,
+	}
+}
+
+func (q *blockingQueue) put(v int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	// 嵌套持有锁, Wait 需要完全释放
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.items) == q.capacity {
+		q.notFull.Wait()
+	}
+	q.items = append(q.items, v)
+	q.notEmpty.Signal()
+}
+
+func (q *blockingQueue) take() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.items) == 0 {
+		q.notEmpty.Wait()
+	}
+	v := q.items[0]
+	q.items = q.items[1:]
+	q.notFull.Signal()
+	return v
+}
+
+func TestCondition_blocking_queue(t *testing.T) {
+	q := newBlockingQueue(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 250; j++ {
+				q.put(i*250 + j)
+			}
+		}(i)
+	}
+	sum := 0
+	for i := 0; i < 1000; i++ {
+		sum += q.take()
+	}
+	wg.Wait()
+	assert.Equal(t, 999*1000/2, sum)
+	assert.False(t, q.lock.HeldByCurrent())
+}
+
+func TestCondition_restore_hold_count(t *testing.T) {
+	var lock ReentrantMutex
+	cond := lock.NewCondition()
+	lock.Lock()
+	lock.Lock()
+	lock.Lock()
+	assert.False(t, cond.WaitTimeout(10*time.Millisecond))
+	assert.Equal(t, 3, lock.HoldCount())
+	lock.Unlock()
+	lock.Unlock()
+	lock.Unlock()
+}
+
+func TestCondition_WaitContext(t *testing.T) {
+	var lock ReentrantMutex
+	cond := lock.NewCondition()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	lock.Lock()
+	assert.ErrorIs(t, cond.WaitContext(ctx), context.Canceled)
+	assert.True(t, lock.HeldByCurrent())
+	lock.Unlock()
+}
+
+func TestCondition_SignalAll(t *testing.T) {
+	var lock ReentrantMutex
+	cond := lock.NewCondition()
+	var wg sync.WaitGroup
+	var waiting, woken int
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock.Lock()
+			waiting++
+			cond.Wait()
+			woken++
+			lock.Unlock()
+		}()
+	}
+	for {
+		lock.Lock()
+		n := waiting
+		lock.Unlock()
+		if n == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	lock.Lock()
+	cond.SignalAll()
+	lock.Unlock()
+	wg.Wait()
+	assert.Equal(t, 5, woken)
+}
+
+func TestCondition_Wait_without_lock_panics(t *testing.T) {
+	var lock ReentrantMutex
+	assert.Panics(t, lock.NewCondition().Wait)
+}
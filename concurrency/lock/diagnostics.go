@@ -0,0 +1,293 @@
+package lock
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ReportKind 诊断报告的类型
+type ReportKind int
+
+const (
+	// LockOrderInversion 锁的获取顺序出现环, 并发执行时可能死锁
+	LockOrderInversion ReportKind = iota
+	// LongHold 锁被持有的时间超过阈值
+	LongHold
+)
+
+func (k ReportKind) String() string {
+	switch k {
+	case LockOrderInversion:
+		return "lock order inversion"
+	case LongHold:
+		return "long lock hold"
+	default:
+		return fmt.Sprintf("ReportKind(%d)", int(k))
+	}
+}
+
+// Report 诊断报告
+type Report struct {
+	Kind    ReportKind
+	Message string
+	Stacks  []string // 相关的获取锁时的调用栈
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Kind.String())
+	sb.WriteString(": ")
+	sb.WriteString(r.Message)
+	for _, stack := range r.Stacks {
+		sb.WriteString("\n")
+		sb.WriteString(stack)
+	}
+	return sb.String()
+}
+
+// DiagnosticsOptions 死锁检测配置
+type DiagnosticsOptions struct {
+	// HoldThreshold 锁被持有超过该时长时报告, 为0时不检查
+	HoldThreshold time.Duration
+	// Reporter 接收诊断报告, 为nil时通过 log 输出
+	Reporter func(Report)
+}
+
+// EnableDiagnostics 开启 ReentrantMutex 的死锁检测
+//
+// 开启后会记录每个协程持有的锁与获取时的调用栈, 根据锁的获取顺序构建有向图, 发现环时报告双方的调用栈,
+// 并报告持有时间超过阈值的锁. 检测会显著降低加锁的性能, 且记录的锁不会被回收, 仅用于调试
+func EnableDiagnostics(opts DiagnosticsOptions) {
+	DisableDiagnostics()
+	diag.mu.Lock()
+	defer diag.mu.Unlock()
+	if opts.Reporter == nil {
+		opts.Reporter = func(r Report) {
+			log.Print(r.String())
+		}
+	}
+	diag.opts = opts
+	diag.held = make(map[unsafe.Pointer][]*heldLock)
+	diag.edges = make(map[*ReentrantMutex]map[*ReentrantMutex]*orderEdge)
+	diag.reported = make(map[[2]*ReentrantMutex]bool)
+	if opts.HoldThreshold > 0 {
+		diag.stop = make(chan struct{})
+		go diag.watch(opts.HoldThreshold, diag.stop)
+	}
+	atomic.StoreInt32(&diagnosing, 1)
+}
+
+// DisableDiagnostics 关闭死锁检测并清空记录
+func DisableDiagnostics() {
+	atomic.StoreInt32(&diagnosing, 0)
+	diag.mu.Lock()
+	defer diag.mu.Unlock()
+	if diag.stop != nil {
+		close(diag.stop)
+		diag.stop = nil
+	}
+	diag.held = nil
+	diag.edges = nil
+	diag.reported = nil
+}
+
+// diagnosing 是否开启了死锁检测
+var diagnosing int32
+
+var diag diagnostics
+
+type diagnostics struct {
+	mu       sync.Mutex
+	opts     DiagnosticsOptions
+	held     map[unsafe.Pointer][]*heldLock                     // 协程 -> 持有的锁, 按获取顺序排列
+	edges    map[*ReentrantMutex]map[*ReentrantMutex]*orderEdge // 锁顺序图, 持有from时获取了to
+	reported map[[2]*ReentrantMutex]bool                        // 已经报告过的锁顺序反转
+	stop     chan struct{}
+}
+
+// heldLock 协程持有的锁
+type heldLock struct {
+	lock     *ReentrantMutex
+	stack    string
+	since    time.Time
+	reported bool
+}
+
+// orderEdge 锁顺序图中的边, 记录第一次出现该顺序时双方的调用栈
+type orderEdge struct {
+	from, to           *ReentrantMutex
+	fromStack, toStack string
+}
+
+// beforeLock 阻塞获取锁之前检查锁顺序, 返回当前的调用栈
+func beforeLock(r *ReentrantMutex, gp unsafe.Pointer) string {
+	if atomic.LoadInt32(&diagnosing) == 0 {
+		return ""
+	}
+	stack := string(debug.Stack())
+	var reports []Report
+	diag.mu.Lock()
+	if diag.held == nil {
+		diag.mu.Unlock()
+		return stack
+	}
+	for _, h := range diag.held[gp] {
+		if h.lock == r {
+			continue
+		}
+		if path := diag.findPath(r, h.lock); path != nil {
+			key := [2]*ReentrantMutex{h.lock, r}
+			if !diag.reported[key] {
+				diag.reported[key] = true
+				reports = append(reports, inversionReport(h, r, stack, path))
+			}
+			continue
+		}
+		tos := diag.edges[h.lock]
+		if tos == nil {
+			tos = make(map[*ReentrantMutex]*orderEdge)
+			diag.edges[h.lock] = tos
+		}
+		if tos[r] == nil {
+			tos[r] = &orderEdge{from: h.lock, to: r, fromStack: h.stack, toStack: stack}
+		}
+	}
+	reporter := diag.opts.Reporter
+	diag.mu.Unlock()
+	for _, report := range reports {
+		reporter(report)
+	}
+	return stack
+}
+
+// afterLock 获取锁之后记录当前协程持有该锁
+func afterLock(r *ReentrantMutex, gp unsafe.Pointer, stack string) {
+	if atomic.LoadInt32(&diagnosing) == 0 {
+		return
+	}
+	if stack == "" {
+		stack = string(debug.Stack())
+	}
+	diag.mu.Lock()
+	defer diag.mu.Unlock()
+	if diag.held == nil {
+		return
+	}
+	diag.held[gp] = append(diag.held[gp], &heldLock{lock: r, stack: stack, since: time.Now()})
+}
+
+// afterUnlock 完全释放锁时移除持有记录, 并检查持有时长
+func afterUnlock(r *ReentrantMutex, gp unsafe.Pointer) {
+	if atomic.LoadInt32(&diagnosing) == 0 {
+		return
+	}
+	diag.mu.Lock()
+	if diag.held == nil {
+		diag.mu.Unlock()
+		return
+	}
+	var h *heldLock
+	held := diag.held[gp]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].lock == r {
+			h = held[i]
+			held = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(diag.held, gp)
+	} else {
+		diag.held[gp] = held
+	}
+	threshold, reporter := diag.opts.HoldThreshold, diag.opts.Reporter
+	diag.mu.Unlock()
+	if h == nil || h.reported || threshold <= 0 {
+		return
+	}
+	if d := time.Since(h.since); d > threshold {
+		reporter(longHoldReport(h, d))
+	}
+}
+
+// findPath 在锁顺序图中查找从from到to的路径
+func (d *diagnostics) findPath(from, to *ReentrantMutex) []*orderEdge {
+	visited := make(map[*ReentrantMutex]bool)
+	var dfs func(cur *ReentrantMutex) []*orderEdge
+	dfs = func(cur *ReentrantMutex) []*orderEdge {
+		visited[cur] = true
+		for next, e := range d.edges[cur] {
+			if next == to {
+				return []*orderEdge{e}
+			}
+			if visited[next] {
+				continue
+			}
+			if path := dfs(next); path != nil {
+				return append([]*orderEdge{e}, path...)
+			}
+		}
+		return nil
+	}
+	return dfs(from)
+}
+
+// watch 定期检查持有时间超过阈值仍未释放的锁
+func (d *diagnostics) watch(threshold time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(threshold / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			var reports []Report
+			d.mu.Lock()
+			for _, held := range d.held {
+				for _, h := range held {
+					if dur := now.Sub(h.since); !h.reported && dur > threshold {
+						h.reported = true
+						reports = append(reports, longHoldReport(h, dur))
+					}
+				}
+			}
+			reporter := d.opts.Reporter
+			d.mu.Unlock()
+			for _, report := range reports {
+				reporter(report)
+			}
+		}
+	}
+}
+
+func inversionReport(h *heldLock, r *ReentrantMutex, stack string, path []*orderEdge) Report {
+	report := Report{
+		Kind: LockOrderInversion,
+		Message: fmt.Sprintf("acquiring lock %p while holding lock %p, but %p was previously acquired before %p",
+			r, h.lock, r, h.lock),
+		Stacks: []string{
+			fmt.Sprintf("lock %p acquired at:\n%s", h.lock, h.stack),
+			fmt.Sprintf("lock %p acquiring at:\n%s", r, stack),
+		},
+	}
+	for _, e := range path {
+		report.Stacks = append(report.Stacks,
+			fmt.Sprintf("previously lock %p acquired at:\n%s", e.from, e.fromStack),
+			fmt.Sprintf("then lock %p acquired at:\n%s", e.to, e.toStack))
+	}
+	return report
+}
+
+func longHoldReport(h *heldLock, d time.Duration) Report {
+	return Report{
+		Kind:    LongHold,
+		Message: fmt.Sprintf("lock %p held for %v", h.lock, d),
+		Stacks:  []string{fmt.Sprintf("lock %p acquired at:\n%s", h.lock, h.stack)},
+	}
+}
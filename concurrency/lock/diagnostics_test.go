@@ -0,0 +1,116 @@
+package lock
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectReports 开启死锁检测并收集报告
+func collectReports(t *testing.T, threshold time.Duration) func() []Report {
+	var mu sync.Mutex
+	var reports []Report
+	EnableDiagnostics(DiagnosticsOptions{
+		HoldThreshold: threshold,
+		Reporter: func(r Report) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, r)
+		},
+	})
+	t.Cleanup(DisableDiagnostics)
+	return func() []Report {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Report(nil), reports...)
+	}
+}
+
+func TestDiagnostics_lock_order_inversion(t *testing.T) {
+	reports := collectReports(t, 0)
+	var a, b ReentrantMutex
+
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	assert.Empty(t, reports())
+
+	done := make(chan struct{})
+	go func() {
+		b.Lock()
+		a.Lock()
+		a.Unlock()
+		b.Unlock()
+		close(done)
+	}()
+	<-done
+
+	rs := reports()
+	if assert.Len(t, rs, 1) {
+		assert.Equal(t, LockOrderInversion, rs[0].Kind)
+		assert.Len(t, rs[0].Stacks, 4)
+		assert.Contains(t, rs[0].String(), "TestDiagnostics_lock_order_inversion")
+	}
+
+	// 相同的反转只报告一次
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	assert.Len(t, reports(), 1)
+}
+
+func TestDiagnostics_transitive_cycle(t *testing.T) {
+	reports := collectReports(t, 0)
+	var a, b, c ReentrantMutex
+	a.WithLock(func() {
+		b.WithLock(func() {})
+	})
+	b.WithLock(func() {
+		c.WithLock(func() {})
+	})
+	c.WithLock(func() {
+		a.WithLock(func() {})
+	})
+	rs := reports()
+	if assert.Len(t, rs, 1) {
+		assert.Equal(t, LockOrderInversion, rs[0].Kind)
+	}
+}
+
+func TestDiagnostics_reentrant_and_try_lock(t *testing.T) {
+	reports := collectReports(t, 0)
+	var a, b ReentrantMutex
+	a.Lock()
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	a.Unlock()
+
+	b.Lock()
+	assert.True(t, a.TryLock())
+	a.Unlock()
+	b.Unlock()
+	assert.Empty(t, reports())
+}
+
+func TestDiagnostics_long_hold(t *testing.T) {
+	reports := collectReports(t, 20*time.Millisecond)
+	var a ReentrantMutex
+	a.Lock()
+	time.Sleep(50 * time.Millisecond)
+	// 仍未释放时就应当被报告
+	rs := reports()
+	if assert.Len(t, rs, 1) {
+		assert.Equal(t, LongHold, rs[0].Kind)
+	}
+	a.Unlock()
+	assert.Len(t, reports(), 1)
+
+	a.Lock()
+	a.Unlock()
+	assert.Len(t, reports(), 1)
+}
@@ -0,0 +1,133 @@
+package lock
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedLocker 按key加锁, 不同key之间互不影响
+//
+// 每个key的锁在第一次使用时创建, 按引用计数管理, 没有协程持有或等待时被移除, 不会随着key的增多而泄漏.
+// 每个key的锁都是 ReentrantMutex, 同一协程可以重复获取同一个key的锁
+type KeyedLocker[K comparable] struct {
+	mu    sync.Mutex
+	locks map[K]*keyedLock
+}
+
+// keyedLock 某个key的锁
+type keyedLock struct {
+	lock ReentrantMutex
+	refs int // 持有(含嵌套)与等待该锁的次数
+}
+
+// NewKeyedLocker 创建一个新的实例
+func NewKeyedLocker[K comparable]() *KeyedLocker[K] {
+	return &KeyedLocker[K]{
+		locks: make(map[K]*keyedLock),
+	}
+}
+
+// Lock 获取key的锁
+func (k *KeyedLocker[K]) Lock(key K) {
+	k.ref(key).lock.Lock()
+}
+
+// Unlock 释放key的锁
+func (k *KeyedLocker[K]) Unlock(key K) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, ok := k.locks[key]
+	if !ok {
+		panic("unlock of unlocked keyed lock")
+	}
+	l.lock.Unlock()
+	k.unref(key, l)
+}
+
+// TryLock 尝试获取key的锁, 已被其他协程持有时立即返回false
+func (k *KeyedLocker[K]) TryLock(key K) bool {
+	l := k.ref(key)
+	if l.lock.TryLock() {
+		return true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.unref(key, l)
+	return false
+}
+
+// LockContext 获取key的锁, ctx结束时放弃等待并返回ctx.Err()
+func (k *KeyedLocker[K]) LockContext(ctx context.Context, key K) error {
+	l := k.ref(key)
+	if err := l.lock.LockContext(ctx); err != nil {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		k.unref(key, l)
+		return err
+	}
+	return nil
+}
+
+// WithLock 持有key的锁执行f
+func (k *KeyedLocker[K]) WithLock(key K, f func()) {
+	k.Lock(key)
+	defer k.Unlock(key)
+	f()
+}
+
+// WithLockContext 持有key的锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err()
+func (k *KeyedLocker[K]) WithLockContext(ctx context.Context, key K, f func()) error {
+	if err := k.LockContext(ctx, key); err != nil {
+		return err
+	}
+	defer k.Unlock(key)
+	f()
+	return nil
+}
+
+// Locker 返回只作用于key的 ContextLocker
+func (k *KeyedLocker[K]) Locker(key K) ContextLocker {
+	return keyLocker[K]{locker: k, key: key}
+}
+
+// Len 当前被持有或等待的key的数量
+func (k *KeyedLocker[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.locks)
+}
+
+// ref 获取key的锁并增加引用计数, 不存在时创建
+func (k *KeyedLocker[K]) ref(key K) *keyedLock {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// unref 减少引用计数, 归零时移除key的锁, 调用前需要持有 k.mu
+func (k *KeyedLocker[K]) unref(key K, l *keyedLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+// keyLocker 作用于某个key的 ContextLocker
+type keyLocker[K comparable] struct {
+	locker *KeyedLocker[K]
+	key    K
+}
+
+func (l keyLocker[K]) WithLock(f func()) {
+	l.locker.WithLock(l.key, f)
+}
+
+func (l keyLocker[K]) WithLockContext(ctx context.Context, f func()) error {
+	return l.locker.WithLockContext(ctx, l.key, f)
+}
@@ -0,0 +1,80 @@
+package lock
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedLocker_serialize_per_key(t *testing.T) {
+	locker := NewKeyedLocker[string]()
+	counts := map[string]*int{"a": new(int), "b": new(int)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		key := "a"
+		if i&1 == 1 {
+			key = "b"
+		}
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				locker.WithLock(key, func() {
+					*counts[key]++
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4000, *counts["a"])
+	assert.Equal(t, 4000, *counts["b"])
+	assert.Equal(t, 0, locker.Len())
+}
+
+func TestKeyedLocker_different_keys_do_not_block(t *testing.T) {
+	locker := NewKeyedLocker[int]()
+	locker.Lock(1)
+	defer locker.Unlock(1)
+	done := make(chan bool)
+	go func() {
+		done <- locker.TryLock(2)
+		locker.Unlock(2)
+	}()
+	assert.True(t, <-done)
+	go func() {
+		done <- locker.TryLock(1)
+	}()
+	assert.False(t, <-done)
+	assert.Equal(t, 1, locker.Len())
+}
+
+func TestKeyedLocker_reentrant_and_release(t *testing.T) {
+	locker := NewKeyedLocker[int]()
+	locker.Lock(1)
+	locker.Lock(1)
+	locker.Unlock(1)
+	assert.Equal(t, 1, locker.Len())
+	locker.Unlock(1)
+	assert.Equal(t, 0, locker.Len())
+	assert.Panics(t, func() {
+		locker.Unlock(1)
+	})
+}
+
+func TestKeyedLocker_LockContext(t *testing.T) {
+	locker := NewKeyedLocker[int]()
+	locker.Lock(1)
+	errCh := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		errCh <- locker.Locker(1).WithLockContext(ctx, func() {
+			t.Error("f should not be executed")
+		})
+	}()
+	assert.ErrorIs(t, <-errCh, context.DeadlineExceeded)
+	locker.Unlock(1)
+	assert.Equal(t, 0, locker.Len())
+}
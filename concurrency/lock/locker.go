@@ -1,5 +1,14 @@
 package lock
 
+import "context"
+
 type Locker interface {
 	WithLock(func())
 }
+
+// ContextLocker 支持限制等待时间的 Locker
+type ContextLocker interface {
+	Locker
+	// WithLockContext 持有锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err()
+	WithLockContext(ctx context.Context, f func()) error
+}
@@ -1,16 +1,44 @@
 package lock
 
 import (
+	"context"
 	"github.com/koleter/go-util/g"
+	"github.com/koleter/go-util/list/dlinkedlist"
 	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
-// ReentrantMutex 可重入锁
+// spinTimes 竞争锁时挂起前的自旋次数
+const spinTimes = 4
+
+// ReentrantMutex 可重入锁, 零值可以直接使用
+//
+// 获取锁失败时先短暂自旋, 之后挂起等待被唤醒, 不会在长时间的临界区内持续占用cpu.
+// 默认为非公平锁, 被唤醒的协程需要与新来的协程重新竞争; 公平锁使用 NewFairReentrantMutex 创建,
+// 释放锁时直接将锁交给等待最久的协程, 避免饥饿
 type ReentrantMutex struct {
 	owner unsafe.Pointer // 当前协程的指针
 	count int32          // 锁的嵌套深度
+	nwait int32          // 等待锁的协程数
+	fair  bool           // 是否为公平锁
+
+	mu      sync.Mutex // 保护 waiters
+	waiters dlinkedlist.DoublyLinkedList[*waiter]
+}
+
+// waiter 挂起等待锁的协程
+type waiter struct {
+	gp    unsafe.Pointer
+	ready chan struct{} // 被唤醒时写入, 公平锁下表示锁已经交给该协程
+	node  *dlinkedlist.Node[*waiter]
+}
+
+// NewFairReentrantMutex 创建公平的可重入锁, 等待的协程按FIFO顺序获得锁
+func NewFairReentrantMutex() *ReentrantMutex {
+	return &ReentrantMutex{fair: true}
 }
 
 // Lock 尝试获取锁
@@ -21,18 +49,9 @@ func (r *ReentrantMutex) Lock() {
 		atomic.AddInt32(&r.count, 1)
 		return
 	}
-
-	// 否则，阻塞当前 goroutine 直到锁可用
-	var iter int
-	for !atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), gp) {
-		iter++
-		if iter == 4 {
-			iter = 0
-			// 自旋4次未获得锁，让出cpu
-			runtime.Gosched()
-		}
-	}
-	atomic.StoreInt32(&r.count, 1)
+	stack := beforeLock(r, gp)
+	r.acquire(gp, nil)
+	afterLock(r, gp, stack)
 }
 
 // Unlock 释放锁
@@ -42,6 +61,229 @@ func (r *ReentrantMutex) Unlock() {
 		panic("unlock of unlocked reentrant mutex")
 	}
 	if atomic.AddInt32(&r.count, -1) == 0 {
-		atomic.StorePointer(&r.owner, unsafe.Pointer(nil))
+		afterUnlock(r, gp)
+		r.release()
+	}
+}
+
+// TryLock 尝试获取锁, 锁被其他协程持有时立即返回false
+//
+// 与 java 的 ReentrantLock.tryLock 相同, 公平锁在锁空闲时也允许插队获取
+func (r *ReentrantMutex) TryLock() bool {
+	gp := g.G()
+	if atomic.LoadPointer(&r.owner) == gp {
+		atomic.AddInt32(&r.count, 1)
+		return true
+	}
+	if atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), gp) {
+		atomic.StoreInt32(&r.count, 1)
+		// TryLock 不会阻塞, 不参与锁顺序检查
+		afterLock(r, gp, "")
+		return true
+	}
+	return false
+}
+
+// TryLockTimeout 在d时长内尝试获取锁, 超时返回false
+func (r *ReentrantMutex) TryLockTimeout(d time.Duration) bool {
+	if d <= 0 {
+		return r.TryLock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return r.LockContext(ctx) == nil
+}
+
+// LockContext 获取锁, ctx结束时放弃等待并返回ctx.Err()
+func (r *ReentrantMutex) LockContext(ctx context.Context) error {
+	gp := g.G()
+	if atomic.LoadPointer(&r.owner) == gp {
+		atomic.AddInt32(&r.count, 1)
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stack := beforeLock(r, gp)
+	if !r.acquire(gp, ctx.Done()) {
+		return ctx.Err()
+	}
+	afterLock(r, gp, stack)
+	return nil
+}
+
+// HeldByCurrent 当前协程是否持有锁
+func (r *ReentrantMutex) HeldByCurrent() bool {
+	return atomic.LoadPointer(&r.owner) == g.G()
+}
+
+// HoldCount 当前协程持有锁的嵌套深度, 未持有时返回0
+func (r *ReentrantMutex) HoldCount() int {
+	if !r.HeldByCurrent() {
+		return 0
+	}
+	return int(atomic.LoadInt32(&r.count))
+}
+
+// WithLock 持有锁执行f
+func (r *ReentrantMutex) WithLock(f func()) {
+	r.Lock()
+	defer r.Unlock()
+	f()
+}
+
+// WithLockContext 持有锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err()
+func (r *ReentrantMutex) WithLockContext(ctx context.Context, f func()) error {
+	if err := r.LockContext(ctx); err != nil {
+		return err
+	}
+	defer r.Unlock()
+	f()
+	return nil
+}
+
+// tryAcquire 在锁空闲时获取锁, 公平锁在有协程等待时不允许插队
+func (r *ReentrantMutex) tryAcquire(gp unsafe.Pointer) bool {
+	if r.fair && atomic.LoadInt32(&r.nwait) > 0 {
+		return false
+	}
+	if atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), gp) {
+		atomic.StoreInt32(&r.count, 1)
+		return true
+	}
+	return false
+}
+
+// spin 自旋若干次尝试获取锁
+func (r *ReentrantMutex) spin(gp unsafe.Pointer) bool {
+	for i := 0; i < spinTimes; i++ {
+		if r.tryAcquire(gp) {
+			return true
+		}
+		runtime.Gosched()
+	}
+	return r.tryAcquire(gp)
+}
+
+// acquire 获取锁, 先自旋再挂起等待, done关闭时放弃等待并返回false
+func (r *ReentrantMutex) acquire(gp unsafe.Pointer, done <-chan struct{}) bool {
+	if r.spin(gp) {
+		return true
+	}
+	if w := r.enqueue(gp); w != nil {
+		return r.wait(w, done)
+	}
+	return true
+}
+
+// enqueue 将当前协程加入等待队列, 若加入前锁已经空闲则直接获取锁并返回nil
+func (r *ReentrantMutex) enqueue(gp unsafe.Pointer) *waiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 先登记等待者再检查锁, 保证与 release 之间不会丢失唤醒
+	atomic.AddInt32(&r.nwait, 1)
+	if (!r.fair || r.waiters.Len() == 0) &&
+		atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), gp) {
+		atomic.AddInt32(&r.nwait, -1)
+		atomic.StoreInt32(&r.count, 1)
+		return nil
+	}
+	w := &waiter{gp: gp, ready: make(chan struct{}, 1)}
+	w.node = r.waiters.PushBack(w)
+	return w
+}
+
+// wait 挂起等待锁, done关闭时放弃等待并返回false
+func (r *ReentrantMutex) wait(w *waiter, done <-chan struct{}) bool {
+	for {
+		select {
+		case <-w.ready:
+			if r.fair || r.reacquire(w) {
+				return true
+			}
+		case <-done:
+			r.abandon(w)
+			return false
+		}
+	}
+}
+
+// abandon 放弃等待, 若已经被唤醒则将唤醒传递给下一个等待者, 避免丢失唤醒
+func (r *ReentrantMutex) abandon(w *waiter) {
+	r.mu.Lock()
+	if w.node != nil {
+		r.waiters.Remove(w.node)
+		w.node = nil
+		atomic.AddInt32(&r.nwait, -1)
+		r.mu.Unlock()
+		return
+	}
+	if r.fair {
+		// 锁已经交给了当前协程, 直接释放
+		r.mu.Unlock()
+		<-w.ready
+		r.release()
+		return
+	}
+	atomic.AddInt32(&r.nwait, -1)
+	next := r.dequeue()
+	r.mu.Unlock()
+	if next != nil {
+		next.ready <- struct{}{}
+	}
+}
+
+// reacquire 非公平锁被唤醒后重新竞争锁, 失败则重新排到队首
+func (r *ReentrantMutex) reacquire(w *waiter) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), w.gp) {
+		atomic.AddInt32(&r.nwait, -1)
+		atomic.StoreInt32(&r.count, 1)
+		return true
+	}
+	w.node = r.waiters.PushFront(w)
+	return false
+}
+
+// dequeue 取出等待最久的协程, 调用前需要持有 r.mu
+func (r *ReentrantMutex) dequeue() *waiter {
+	head := r.waiters.Head()
+	if head == nil {
+		return nil
+	}
+	w := head.Value
+	r.waiters.Remove(head)
+	w.node = nil
+	return w
+}
+
+// release 嵌套深度归零后释放锁并唤醒等待者
+func (r *ReentrantMutex) release() {
+	if r.fair {
+		r.mu.Lock()
+		w := r.dequeue()
+		if w == nil {
+			atomic.StorePointer(&r.owner, unsafe.Pointer(nil))
+			r.mu.Unlock()
+			return
+		}
+		// 直接将锁交给等待者
+		atomic.AddInt32(&r.nwait, -1)
+		atomic.StorePointer(&r.owner, w.gp)
+		atomic.StoreInt32(&r.count, 1)
+		r.mu.Unlock()
+		w.ready <- struct{}{}
+		return
+	}
+	atomic.StorePointer(&r.owner, unsafe.Pointer(nil))
+	if atomic.LoadInt32(&r.nwait) == 0 {
+		return
+	}
+	r.mu.Lock()
+	w := r.dequeue()
+	r.mu.Unlock()
+	if w != nil {
+		w.ready <- struct{}{}
 	}
 }
@@ -0,0 +1,104 @@
+//go:build unix
+
+package lock
+
+import (
+	"github.com/koleter/go-util/g"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// spinReentrantMutex 旧版本自旋加让出cpu实现的可重入锁, 仅用于性能对比
+type spinReentrantMutex struct {
+	owner unsafe.Pointer
+	count int32
+}
+
+func (r *spinReentrantMutex) Lock() {
+	gp := g.G()
+	if atomic.LoadPointer(&r.owner) == gp {
+		atomic.AddInt32(&r.count, 1)
+		return
+	}
+	var iter int
+	for !atomic.CompareAndSwapPointer(&r.owner, unsafe.Pointer(nil), gp) {
+		iter++
+		if iter == 4 {
+			iter = 0
+			runtime.Gosched()
+		}
+	}
+	atomic.StoreInt32(&r.count, 1)
+}
+
+func (r *spinReentrantMutex) Unlock() {
+	if atomic.AddInt32(&r.count, -1) == 0 {
+		atomic.StorePointer(&r.owner, unsafe.Pointer(nil))
+	}
+}
+
+// cpuTime 返回进程已使用的用户态与内核态cpu时间
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// benchmarkContention 多个协程竞争同一把锁, 临界区内休眠hold时长模拟长时间持有锁(如等待io),
+// 额外报告cpu时间, 用于对比等待者挂起与自旋的cpu占用
+func benchmarkContention(b *testing.B, l sync.Locker, hold time.Duration) {
+	const goroutines = 8
+	var wg sync.WaitGroup
+	var n int64
+	b.ResetTimer()
+	startCPU, start := cpuTime(), time.Now()
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.AddInt64(&n, 1) <= int64(b.N) {
+				l.Lock()
+				if hold > 0 {
+					time.Sleep(hold)
+				}
+				l.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	cpu, wall := cpuTime()-startCPU, time.Since(start)
+	b.StopTimer()
+	b.ReportMetric(float64(cpu.Nanoseconds())/float64(b.N), "cpu-ns/op")
+	b.ReportMetric(float64(cpu)/float64(wall), "cpu-cores")
+}
+
+func BenchmarkReentrantMutex_Contention(b *testing.B) {
+	benchmarkContention(b, new(ReentrantMutex), 0)
+}
+
+func BenchmarkReentrantMutex_Fair_Contention(b *testing.B) {
+	benchmarkContention(b, NewFairReentrantMutex(), 0)
+}
+
+func BenchmarkSpinReentrantMutex_Contention(b *testing.B) {
+	benchmarkContention(b, new(spinReentrantMutex), 0)
+}
+
+func BenchmarkReentrantMutex_LongCriticalSection(b *testing.B) {
+	benchmarkContention(b, new(ReentrantMutex), 100*time.Microsecond)
+}
+
+func BenchmarkReentrantMutex_Fair_LongCriticalSection(b *testing.B) {
+	benchmarkContention(b, NewFairReentrantMutex(), 100*time.Microsecond)
+}
+
+func BenchmarkSpinReentrantMutex_LongCriticalSection(b *testing.B) {
+	benchmarkContention(b, new(spinReentrantMutex), 100*time.Microsecond)
+}
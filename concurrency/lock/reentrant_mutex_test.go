@@ -1,9 +1,12 @@
 package lock
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestReentrantMutex(t *testing.T) {
@@ -33,3 +36,147 @@ func TestReentrantMutex(t *testing.T) {
 	wg.Wait()
 	assert.Equal(t, 20000, c)
 }
+
+func TestReentrantMutex_Reentrant(t *testing.T) {
+	var lock ReentrantMutex
+	lock.Lock()
+	lock.Lock()
+	lock.Unlock()
+	lock.Unlock()
+	assert.Panics(t, lock.Unlock)
+}
+
+func TestReentrantMutex_parked_waiters(t *testing.T) {
+	for _, lock := range []*ReentrantMutex{new(ReentrantMutex), NewFairReentrantMutex()} {
+		var wg sync.WaitGroup
+		var c int
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					lock.Lock()
+					lock.Lock()
+					c++
+					lock.Unlock()
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 8000, c)
+		assert.Equal(t, int32(0), lock.nwait)
+		assert.Nil(t, lock.owner)
+	}
+}
+
+func TestReentrantMutex_fair_FIFO(t *testing.T) {
+	lock := NewFairReentrantMutex()
+	lock.Lock()
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+		}(i)
+		// 等待该协程进入等待队列, 保证入队顺序
+		for atomic.LoadInt32(&lock.nwait) != int32(i+1) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	lock.Unlock()
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+}
+
+func TestReentrantMutex_TryLock(t *testing.T) {
+	var lock ReentrantMutex
+	assert.True(t, lock.TryLock())
+	assert.True(t, lock.TryLock())
+	assert.Equal(t, 2, lock.HoldCount())
+	assert.True(t, lock.HeldByCurrent())
+
+	res := make(chan bool)
+	go func() {
+		res <- lock.TryLock()
+		res <- lock.HeldByCurrent()
+	}()
+	assert.False(t, <-res)
+	assert.False(t, <-res)
+
+	lock.Unlock()
+	lock.Unlock()
+	assert.Equal(t, 0, lock.HoldCount())
+	assert.False(t, lock.HeldByCurrent())
+}
+
+func TestReentrantMutex_TryLockTimeout(t *testing.T) {
+	for _, lock := range []*ReentrantMutex{new(ReentrantMutex), NewFairReentrantMutex()} {
+		lock.Lock()
+		res := make(chan bool)
+		go func() {
+			res <- lock.TryLockTimeout(20 * time.Millisecond)
+		}()
+		assert.False(t, <-res)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&lock.nwait))
+
+		go func() {
+			ok := lock.TryLockTimeout(time.Second)
+			if ok {
+				lock.Unlock()
+			}
+			res <- ok
+		}()
+		time.Sleep(20 * time.Millisecond)
+		lock.Unlock()
+		assert.True(t, <-res)
+	}
+}
+
+func TestReentrantMutex_LockContext(t *testing.T) {
+	var lock ReentrantMutex
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, lock.LockContext(ctx))
+	assert.NoError(t, lock.LockContext(ctx))
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- lock.WithLockContext(ctx, func() {
+			t.Error("f should not be executed")
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+
+	lock.Unlock()
+	lock.Unlock()
+	assert.ErrorIs(t, lock.LockContext(ctx), context.Canceled)
+	assert.False(t, lock.HeldByCurrent())
+}
+
+// 等待者取消时锁的唤醒不能丢失
+func TestReentrantMutex_cancel_does_not_lose_wakeup(t *testing.T) {
+	for _, lock := range []*ReentrantMutex{new(ReentrantMutex), NewFairReentrantMutex()} {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					if lock.TryLockTimeout(time.Duration(j%3) * 50 * time.Microsecond) {
+						lock.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		assert.True(t, lock.TryLockTimeout(time.Second))
+		lock.Unlock()
+		assert.Equal(t, int32(0), atomic.LoadInt32(&lock.nwait))
+	}
+}
@@ -0,0 +1,163 @@
+package lock
+
+import (
+	"github.com/koleter/go-util/g"
+	"sync"
+	"unsafe"
+)
+
+// ReentrantRWMutex 可重入读写锁, 按协程记录读锁与写锁的持有情况
+//
+// 持有写锁的协程可以再次获取读锁或写锁; 写锁可以通过 Downgrade 降级为读锁;
+// 读锁可以通过 TryUpgrade 升级为写锁, 当已有其他协程在等待升级时升级失败而不是死锁
+type ReentrantRWMutex struct {
+	mu             sync.Mutex
+	cond           *sync.Cond
+	writer         unsafe.Pointer         // 持有写锁的协程
+	writeCount     int                    // 写锁的嵌套深度
+	readers        map[unsafe.Pointer]int // 各协程持有读锁的嵌套深度
+	waitingWriters int                    // 等待写锁的协程数, 用于避免写锁饥饿
+	upgrading      unsafe.Pointer         // 正在等待升级的协程
+}
+
+func (rw *ReentrantRWMutex) init() {
+	if rw.cond == nil {
+		rw.cond = sync.NewCond(&rw.mu)
+		rw.readers = make(map[unsafe.Pointer]int)
+	}
+}
+
+// Lock 获取写锁
+func (rw *ReentrantRWMutex) Lock() {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.init()
+	if rw.writer == gp {
+		rw.writeCount++
+		return
+	}
+	if rw.readers[gp] > 0 {
+		panic("lock of reentrant rwmutex while holding read lock, use TryUpgrade instead")
+	}
+	rw.waitingWriters++
+	for rw.writer != nil || len(rw.readers) > 0 || rw.upgrading != nil {
+		rw.cond.Wait()
+	}
+	rw.waitingWriters--
+	rw.writer = gp
+	rw.writeCount = 1
+}
+
+// Unlock 释放写锁
+func (rw *ReentrantRWMutex) Unlock() {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer != gp {
+		panic("unlock of unlocked reentrant rwmutex")
+	}
+	rw.writeCount--
+	if rw.writeCount == 0 {
+		rw.writer = nil
+		rw.cond.Broadcast()
+	}
+}
+
+// RLock 获取读锁
+func (rw *ReentrantRWMutex) RLock() {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.init()
+	// 已持有写锁或读锁时直接重入, 不能等待写者, 否则会死锁
+	if rw.writer == gp || rw.readers[gp] > 0 {
+		rw.readers[gp]++
+		return
+	}
+	for rw.writer != nil || rw.waitingWriters > 0 || rw.upgrading != nil {
+		rw.cond.Wait()
+	}
+	rw.readers[gp]++
+}
+
+// RUnlock 释放读锁
+func (rw *ReentrantRWMutex) RUnlock() {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	n := rw.readers[gp]
+	if n <= 0 {
+		panic("runlock of unlocked reentrant rwmutex")
+	}
+	if n == 1 {
+		delete(rw.readers, gp)
+		rw.cond.Broadcast()
+	} else {
+		rw.readers[gp] = n - 1
+	}
+}
+
+// Downgrade 将一层写锁降级为读锁, 期间不会释放锁
+//
+// 相当于原子地执行 RLock 与 Unlock, 调用后需要使用 RUnlock 释放
+func (rw *ReentrantRWMutex) Downgrade() {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.writer != gp {
+		panic("downgrade of unlocked reentrant rwmutex")
+	}
+	rw.readers[gp]++
+	rw.writeCount--
+	if rw.writeCount == 0 {
+		rw.writer = nil
+		rw.cond.Broadcast()
+	}
+}
+
+// TryUpgrade 尝试将一层读锁升级为写锁, 会等待其他读者释放读锁
+//
+// 若已有其他协程在等待升级, 两者互相等待对方释放读锁必然死锁, 此时直接返回 false,
+// 调用方应当释放读锁后重试. 升级成功相当于原子地执行 Lock 与 RUnlock, 调用后需要使用 Unlock 释放
+func (rw *ReentrantRWMutex) TryUpgrade() bool {
+	gp := g.G()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	n := rw.readers[gp]
+	if n <= 0 {
+		panic("upgrade of unlocked reentrant rwmutex")
+	}
+	if rw.writer != gp {
+		if rw.upgrading != nil {
+			return false
+		}
+		rw.upgrading = gp
+		for rw.writer != nil || len(rw.readers) > 1 {
+			rw.cond.Wait()
+		}
+		rw.upgrading = nil
+		rw.writer = gp
+	}
+	rw.writeCount++
+	if n == 1 {
+		delete(rw.readers, gp)
+	} else {
+		rw.readers[gp] = n - 1
+	}
+	return true
+}
+
+// WithLock 持有写锁执行f
+func (rw *ReentrantRWMutex) WithLock(f func()) {
+	rw.Lock()
+	defer rw.Unlock()
+	f()
+}
+
+// WithRLock 持有读锁执行f
+func (rw *ReentrantRWMutex) WithRLock(f func()) {
+	rw.RLock()
+	defer rw.RUnlock()
+	f()
+}
@@ -0,0 +1,131 @@
+package lock
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReentrantRWMutex_concurrent_write(t *testing.T) {
+	var rw ReentrantRWMutex
+	var wg sync.WaitGroup
+	var c int
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5000; j++ {
+				rw.Lock()
+				c++
+				rw.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 20000, c)
+}
+
+func TestReentrantRWMutex_reentrant(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.Lock()
+	rw.Lock()
+	rw.RLock()
+	rw.RUnlock()
+	rw.Unlock()
+	rw.Unlock()
+
+	rw.RLock()
+	rw.RLock()
+	rw.RUnlock()
+	rw.RUnlock()
+	assert.Panics(t, rw.RUnlock)
+	assert.Panics(t, rw.Unlock)
+}
+
+func TestReentrantRWMutex_multiple_readers(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.RLock()
+	done := make(chan struct{})
+	go func() {
+		rw.RLock()
+		rw.RUnlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reader blocked by another reader")
+	}
+	rw.RUnlock()
+}
+
+func TestReentrantRWMutex_Downgrade(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.Lock()
+	rw.Downgrade()
+
+	readDone := make(chan struct{})
+	go func() {
+		rw.RLock()
+		rw.RUnlock()
+		close(readDone)
+	}()
+	<-readDone
+
+	writeDone := make(chan struct{})
+	go func() {
+		rw.Lock()
+		rw.Unlock()
+		close(writeDone)
+	}()
+	select {
+	case <-writeDone:
+		t.Fatal("writer acquired lock while read lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	rw.RUnlock()
+	<-writeDone
+}
+
+func TestReentrantRWMutex_TryUpgrade(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.RLock()
+	assert.True(t, rw.TryUpgrade())
+	rw.Unlock()
+	assert.Panics(t, rw.RUnlock)
+}
+
+func TestReentrantRWMutex_TryUpgrade_two_readers(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.RLock()
+	ready := make(chan struct{})
+	upgraded := make(chan bool, 1)
+	go func() {
+		rw.RLock()
+		close(ready)
+		// 等待主协程进入升级等待状态
+		for {
+			rw.mu.Lock()
+			waiting := rw.upgrading != nil
+			rw.mu.Unlock()
+			if waiting {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		upgraded <- rw.TryUpgrade()
+		rw.RUnlock()
+	}()
+	<-ready
+	assert.True(t, rw.TryUpgrade())
+	assert.False(t, <-upgraded)
+	rw.Unlock()
+}
+
+func TestReentrantRWMutex_Lock_while_reading_panics(t *testing.T) {
+	var rw ReentrantRWMutex
+	rw.RLock()
+	defer rw.RUnlock()
+	assert.Panics(t, rw.Lock)
+}
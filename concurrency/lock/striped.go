@@ -0,0 +1,178 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Striped 固定数量的锁数组, key按哈希值映射到其中一把锁上
+//
+// 与 KeyedLocker 相比不需要维护每个key的锁, 内存占用固定, 但不同的key可能映射到同一把锁上
+type Striped[K comparable] struct {
+	locks []ReentrantMutex
+	mask  uint64
+	hash  func(K) uint64
+}
+
+// NewStriped 创建包含stripes把锁的实例, 数量会向上取整为2的幂
+//
+// hash为nil时使用默认的哈希函数, 支持整数、浮点数、复数、布尔、字符串、指针、chan与unsafe.Pointer,
+// 指针按地址计算哈希; 结构体、数组等其他类型必须自行提供hash, 否则panic
+func NewStriped[K comparable](stripes int, hash func(K) uint64) *Striped[K] {
+	if stripes <= 0 {
+		panic(fmt.Sprintf("stripes is less than or equal to 0, stripes: %d", stripes))
+	}
+	n := 1
+	for n < stripes {
+		n <<= 1
+	}
+	if hash == nil {
+		hash = defaultHash[K]()
+		if hash == nil {
+			panic(fmt.Sprintf("NewStriped: no default hash for key type %s, provide a hash function", reflect.TypeOf((*K)(nil)).Elem()))
+		}
+	}
+	return &Striped[K]{
+		locks: make([]ReentrantMutex, n),
+		mask:  uint64(n - 1),
+		hash:  hash,
+	}
+}
+
+// Stripes 锁的数量
+func (s *Striped[K]) Stripes() int {
+	return len(s.locks)
+}
+
+// Get 返回key映射到的锁
+func (s *Striped[K]) Get(key K) *ReentrantMutex {
+	return &s.locks[s.index(key)]
+}
+
+// Lock 获取key的锁
+func (s *Striped[K]) Lock(key K) {
+	s.Get(key).Lock()
+}
+
+// Unlock 释放key的锁
+func (s *Striped[K]) Unlock(key K) {
+	s.Get(key).Unlock()
+}
+
+// WithLock 持有key的锁执行f
+func (s *Striped[K]) WithLock(key K, f func()) {
+	s.Get(key).WithLock(f)
+}
+
+// WithLockContext 持有key的锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err()
+func (s *Striped[K]) WithLockContext(ctx context.Context, key K, f func()) error {
+	return s.Get(key).WithLockContext(ctx, f)
+}
+
+// WithLocks 同时持有多个key的锁执行f, 按锁的下标顺序获取以避免死锁
+func (s *Striped[K]) WithLocks(keys []K, f func()) {
+	indexes := make([]int, 0, len(keys))
+	seen := make(map[int]bool, len(keys))
+	for _, key := range keys {
+		i := s.index(key)
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		s.locks[i].Lock()
+	}
+	defer func() {
+		for j := len(indexes) - 1; j >= 0; j-- {
+			s.locks[indexes[j]].Unlock()
+		}
+	}()
+	f()
+}
+
+// Locker 返回key映射到的锁
+func (s *Striped[K]) Locker(key K) ContextLocker {
+	return s.Get(key)
+}
+
+func (s *Striped[K]) index(key K) int {
+	return int(mix(s.hash(key)) & s.mask)
+}
+
+// mix 打散哈希值的低位, 避免连续的整数key集中在少数锁上
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h
+}
+
+// defaultHash 返回K的默认哈希函数, K不是整数、浮点数、复数、布尔、字符串、指针、chan或unsafe.Pointer时返回nil.
+//
+// 指针与chan按地址计算哈希, 修改指向的对象不会改变映射到的锁; 浮点数按值计算, +0与-0映射到同一把锁
+func defaultHash[K comparable]() func(K) uint64 {
+	switch any(*new(K)).(type) {
+	case int:
+		return func(key K) uint64 { return uint64(any(key).(int)) }
+	case int64:
+		return func(key K) uint64 { return uint64(any(key).(int64)) }
+	case uint64:
+		return func(key K) uint64 { return any(key).(uint64) }
+	case string:
+		return func(key K) uint64 { return stringHash(any(key).(string)) }
+	}
+	typeOf := reflect.TypeOf((*K)(nil)).Elem()
+	if _, ok := hashValue(reflect.Zero(typeOf)); !ok {
+		return nil
+	}
+	return func(key K) uint64 {
+		h, _ := hashValue(reflect.ValueOf(key))
+		return h
+	}
+}
+
+// hashValue 按类别计算v的哈希值, 不支持的类别返回false
+func hashValue(v reflect.Value) (uint64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case reflect.Float32, reflect.Float64:
+		return floatHash(v.Float()), true
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return floatHash(real(c))*31 + floatHash(imag(c)), true
+	case reflect.String:
+		return stringHash(v.String()), true
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return uint64(v.Pointer()), true
+	default:
+		return 0, false
+	}
+}
+
+// floatHash 相等的浮点数哈希值相同, +0与-0都按0计算
+func floatHash(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+func stringHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestNewStriped_round_up_to_power_of_two(t *testing.T) {
+	assert.Equal(t, 16, NewStriped[int](10, nil).Stripes())
+	assert.Equal(t, 1, NewStriped[int](1, nil).Stripes())
+	assert.Panics(t, func() {
+		NewStriped[int](0, nil)
+	})
+}
+
+func TestStriped_same_key_same_lock(t *testing.T) {
+	type key struct {
+		a int
+		b string
+	}
+	striped := NewStriped[key](8, func(k key) uint64 {
+		return uint64(k.a)*31 + stringHash(k.b)
+	})
+	assert.Same(t, striped.Get(key{1, "x"}), striped.Get(key{1, "x"}))
+
+	strs := NewStriped[string](8, nil)
+	assert.Same(t, strs.Get("user-1"), strs.Get("user-1"))
+}
+
+func TestStriped_spread_int_keys(t *testing.T) {
+	striped := NewStriped[int](8, nil)
+	used := make(map[*ReentrantMutex]bool)
+	for i := 0; i < 64; i++ {
+		used[striped.Get(i)] = true
+	}
+	assert.Greater(t, len(used), 4)
+}
+
+func TestStriped_WithLocks(t *testing.T) {
+	striped := NewStriped[int](4, nil)
+	var wg sync.WaitGroup
+	var c int
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		keys := []int{i, i + 1, i + 2}
+		if i&1 == 1 {
+			keys = []int{i + 2, i + 1, i}
+		}
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				striped.WithLocks(append(keys, 0), func() {
+					c++
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2000, c)
+}
+
+func TestNewStriped_requires_hash_for_unsupported_type(t *testing.T) {
+	type key struct{ a int }
+	assert.Panics(t, func() { NewStriped[key](8, nil) })
+	assert.Panics(t, func() { NewStriped[[2]int](8, nil) })
+}
+
+func TestStriped_pointer_key_hashed_by_address(t *testing.T) {
+	type user struct{ name string }
+	striped := NewStriped[*user](1024, nil)
+	u := &user{name: "a"}
+	before := striped.Get(u)
+	for i := 0; i < 100; i++ {
+		u.name = strconv.Itoa(i)
+		assert.Same(t, before, striped.Get(u))
+	}
+
+	ch := make(chan int)
+	chans := NewStriped[chan int](1024, nil)
+	assert.Same(t, chans.Get(ch), chans.Get(ch))
+}
+
+func TestStriped_float_zero(t *testing.T) {
+	striped := NewStriped[float64](1024, nil)
+	assert.Same(t, striped.Get(0), striped.Get(math.Copysign(0, -1)))
+	assert.Same(t, striped.Get(1.5), striped.Get(1.5))
+
+	type ID int32
+	ids := NewStriped[ID](1024, nil)
+	assert.Same(t, ids.Get(7), ids.Get(7))
+}
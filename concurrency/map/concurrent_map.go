@@ -1,6 +1,7 @@
 package _map
 
 import (
+	"context"
 	"github.com/koleter/go-util/concurrency/lock"
 )
 
@@ -25,6 +26,14 @@ func (t *ThreadSafeMap[K, V]) WithLock(f func()) {
 	f()
 }
 
+var _ lock.ContextLocker = (*ThreadSafeMap[int, int])(nil)
+
+// WithLockContext 持有锁执行f, ctx在获取锁之前结束时不执行f并返回ctx.Err();
+// ConcurrentMap 只要求 lock.Locker, 需要时通过类型断言 lock.ContextLocker 使用
+func (t *ThreadSafeMap[K, V]) WithLockContext(ctx context.Context, f func()) error {
+	return t.lock.WithLockContext(ctx, f)
+}
+
 func (t *ThreadSafeMap[K, V]) Put(key K, val V) V {
 	t.lock.Lock()
 	defer t.lock.Unlock()
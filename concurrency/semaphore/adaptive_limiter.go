@@ -0,0 +1,119 @@
+package semaphore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock 时钟, 测试时可以替换为手动推进的实现
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Outcome 请求的结果
+type Outcome int
+
+const (
+	// Success 请求成功, 耗时计入采样
+	Success Outcome = iota
+	// Dropped 请求被丢弃或超时, 表示下游过载
+	Dropped
+	// Ignored 与下游容量无关的失败(如参数错误), 不计入采样
+	Ignored
+)
+
+// AdaptiveLimiter 自适应并发限制器, 在 Semaphore 的基础上根据请求的延迟与丢弃情况动态调整并发上限
+type AdaptiveLimiter struct {
+	sem       *Semaphore
+	algorithm LimitAlgorithm
+	clock     Clock
+	mu        sync.Mutex // 串行调用 algorithm
+}
+
+// Token 从 AdaptiveLimiter 获得的许可, 请求结束后必须调用一次 Release
+type Token struct {
+	limiter  *AdaptiveLimiter
+	start    time.Time
+	inflight int
+	released int32
+}
+
+// NewAdaptiveLimiter 创建一个新的实例, clock为nil时使用系统时钟
+func NewAdaptiveLimiter(algorithm LimitAlgorithm, clock Clock) *AdaptiveLimiter {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &AdaptiveLimiter{
+		sem:       NewSemaphore(algorithm.Limit()),
+		algorithm: algorithm,
+		clock:     clock,
+	}
+}
+
+// Acquire 获取许可, 达到并发上限时等待, ctx结束时返回ctx.Err()
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (*Token, error) {
+	if err := l.sem.AcquireContext(ctx, 1); err != nil {
+		return nil, err
+	}
+	return l.newToken(), nil
+}
+
+// TryAcquire 尝试获取许可, 达到并发上限时立即返回false, 适用于直接拒绝超出容量的请求
+func (l *AdaptiveLimiter) TryAcquire() (*Token, bool) {
+	if !l.sem.TryAcquire(1) {
+		return nil, false
+	}
+	return l.newToken(), true
+}
+
+// Limit 当前的并发上限
+func (l *AdaptiveLimiter) Limit() int {
+	return l.sem.Limit()
+}
+
+// InFlight 正在执行的请求数
+func (l *AdaptiveLimiter) InFlight() int {
+	return l.sem.InUse()
+}
+
+// Stats 底层信号量的使用统计
+func (l *AdaptiveLimiter) Stats() Stats {
+	return l.sem.Stats()
+}
+
+// Close 关闭限制器, 唤醒所有等待者并返回 ErrClosed
+func (l *AdaptiveLimiter) Close() {
+	l.sem.Close()
+}
+
+func (l *AdaptiveLimiter) newToken() *Token {
+	return &Token{
+		limiter:  l,
+		start:    l.clock.Now(),
+		inflight: l.sem.InUse(),
+	}
+}
+
+// Release 归还许可并报告请求的结果, 重复调用会被忽略
+func (t *Token) Release(outcome Outcome) {
+	if !atomic.CompareAndSwapInt32(&t.released, 0, 1) {
+		return
+	}
+	l := t.limiter
+	if outcome != Ignored {
+		rtt := l.clock.Now().Sub(t.start)
+		l.mu.Lock()
+		limit := l.algorithm.Update(rtt, t.inflight, outcome == Dropped)
+		l.sem.SetLimit(limit)
+		l.mu.Unlock()
+	}
+	_ = l.sem.Release(1)
+}
@@ -0,0 +1,143 @@
+package semaphore_test
+
+import (
+	"context"
+	"errors"
+	"github.com/koleter/go-util/concurrency/semaphore"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// simulate 模拟容量为capacity的下游, 始终按当前上限发起请求, 每次推进时钟到最早完成的请求并释放许可.
+// 请求开始时正在执行的请求数超过容量后延迟按比例升高, 超过两倍容量时请求超时被丢弃, 返回每个请求完成后的上限
+func simulate(limiter *semaphore.AdaptiveLimiter, clock *fakeClock, capacity, requests int) []int {
+	const base = 10 * time.Millisecond
+	type request struct {
+		token   *semaphore.Token
+		end     time.Time
+		dropped bool
+	}
+	var inflight []request
+	var limits []int
+	for len(limits) < requests {
+		for {
+			token, ok := limiter.TryAcquire()
+			if !ok {
+				break
+			}
+			n := len(inflight) + 1
+			latency := base
+			if n > capacity {
+				latency = base * time.Duration(n) / time.Duration(capacity)
+			}
+			inflight = append(inflight, request{token: token, end: clock.Now().Add(latency), dropped: n > 2*capacity})
+		}
+		first := 0
+		for i, r := range inflight {
+			if r.end.Before(inflight[first].end) {
+				first = i
+			}
+		}
+		r := inflight[first]
+		inflight = append(inflight[:first], inflight[first+1:]...)
+		clock.Advance(r.end.Sub(clock.Now()))
+		if r.dropped {
+			r.token.Release(semaphore.Dropped)
+		} else {
+			r.token.Release(semaphore.Success)
+		}
+		limits = append(limits, limiter.Limit())
+	}
+	return limits
+}
+
+func TestAdaptiveLimiter_converge(t *testing.T) {
+	const capacity = 50
+	opts := semaphore.LimitOptions{Initial: 10, Max: 500}
+	algorithms := map[string]func() semaphore.LimitAlgorithm{
+		"aimd": func() semaphore.LimitAlgorithm {
+			return semaphore.NewAIMDLimit(opts, 0.9, 0)
+		},
+		"vegas": func() semaphore.LimitAlgorithm {
+			return semaphore.NewVegasLimit(opts)
+		},
+		"gradient": func() semaphore.LimitAlgorithm {
+			return semaphore.NewGradientLimit(opts, 0.2, 100)
+		},
+	}
+	for name, newAlgorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{}
+			limiter := semaphore.NewAdaptiveLimiter(newAlgorithm(), clock)
+			limits := simulate(limiter, clock, capacity, 20000)
+			// 收敛后既不会失控增长到最大值, 也不会降到最小值
+			for _, limit := range limits[10000:] {
+				if limit < capacity/2 || limit > 5*capacity/2 {
+					t.Fatalf("limit %d did not converge around capacity %d", limit, capacity)
+				}
+			}
+			// 模拟是确定性的, 重复执行结果相同
+			clock2 := &fakeClock{}
+			limits2 := simulate(semaphore.NewAdaptiveLimiter(newAlgorithm(), clock2), clock2, capacity, 20000)
+			for i := range limits {
+				if limits[i] != limits2[i] {
+					t.Fatalf("simulation is not deterministic at round %d", i)
+				}
+			}
+		})
+	}
+}
+
+// Vegas根据延迟估算排队, 应当收敛在下游容量附近, 不需要等到请求被丢弃
+func TestAdaptiveLimiter_vegas_converge_near_capacity(t *testing.T) {
+	const capacity = 50
+	clock := &fakeClock{}
+	limiter := semaphore.NewAdaptiveLimiter(semaphore.NewVegasLimit(semaphore.LimitOptions{Initial: 10, Max: 500}), clock)
+	limits := simulate(limiter, clock, capacity, 20000)
+	for _, limit := range limits[10000:] {
+		if limit < capacity || limit > 3*capacity/2 {
+			t.Fatalf("limit %d did not converge near capacity %d", limit, capacity)
+		}
+	}
+}
+
+func TestAdaptiveLimiter_grow_without_overload(t *testing.T) {
+	clock := &fakeClock{}
+	limiter := semaphore.NewAdaptiveLimiter(semaphore.NewAIMDLimit(semaphore.LimitOptions{Initial: 10, Max: 100}, 0, 0), clock)
+	simulate(limiter, clock, 1000, 20000)
+	if limiter.Limit() != 100 {
+		t.Errorf("expected limit to grow to max 100, got %d", limiter.Limit())
+	}
+}
+
+func TestAdaptiveLimiter_Acquire(t *testing.T) {
+	clock := &fakeClock{}
+	limiter := semaphore.NewAdaptiveLimiter(semaphore.NewAIMDLimit(semaphore.LimitOptions{Initial: 1}, 0.5, 0), clock)
+	token, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	token.Release(semaphore.Ignored)
+	token.Release(semaphore.Dropped)
+	if limiter.Limit() != 1 || limiter.InFlight() != 0 {
+		t.Errorf("ignored outcome should not change the limit, got limit %d inflight %d", limiter.Limit(), limiter.InFlight())
+	}
+}
@@ -0,0 +1,208 @@
+package semaphore
+
+import (
+	"math"
+	"time"
+)
+
+// LimitAlgorithm 根据请求的延迟与丢弃情况调整并发上限, 由 AdaptiveLimiter 串行调用, 不需要自行保证线程安全
+type LimitAlgorithm interface {
+	// Limit 当前的并发上限
+	Limit() int
+	// Update 报告一次请求的采样, rtt为请求耗时, inflight为请求开始时正在执行的请求数(含自身),
+	// dropped表示请求被丢弃或超时, 返回新的并发上限
+	Update(rtt time.Duration, inflight int, dropped bool) int
+}
+
+// LimitOptions 并发上限算法的通用配置, 零值字段使用默认值
+type LimitOptions struct {
+	Initial int // 初始上限, 默认20
+	Min     int // 最小上限, 默认1
+	Max     int // 最大上限, 默认1000
+}
+
+func (o LimitOptions) withDefaults() LimitOptions {
+	if o.Min <= 0 {
+		o.Min = 1
+	}
+	if o.Max <= 0 {
+		o.Max = 1000
+	}
+	if o.Max < o.Min {
+		o.Max = o.Min
+	}
+	if o.Initial <= 0 {
+		o.Initial = 20
+	}
+	return o
+}
+
+func (o LimitOptions) clamp(limit float64) float64 {
+	return math.Max(float64(o.Min), math.Min(float64(o.Max), limit))
+}
+
+// appLimited 正在执行的请求远小于上限, 此时的采样不能说明系统还能承受更高的并发
+func appLimited(inflight int, limit float64) bool {
+	return float64(inflight)*2 < limit
+}
+
+// staleDrop 请求开始时正在执行的请求数已经超过当前上限, 说明请求在上一次减小上限之前就已经发出,
+// 它的失败已经体现在之前的调整中, 不应当再次减小上限, 否则一次过载会让上限连续减小到最小值
+func staleDrop(inflight int, limit float64) bool {
+	return float64(inflight) > limit
+}
+
+// AIMDLimit 加性增乘性减算法, 与TCP拥塞避免相同, 每轮(约limit个请求)成功时上限加一, 请求被丢弃或超时时上限乘以退避系数
+type AIMDLimit struct {
+	opts         LimitOptions
+	backoffRatio float64
+	timeout      time.Duration
+	limit        float64
+}
+
+// NewAIMDLimit 创建AIMD算法, backoffRatio为退避系数, 不在(0,1)内时使用0.9;
+// timeout大于0时耗时超过timeout的请求视为丢弃
+func NewAIMDLimit(opts LimitOptions, backoffRatio float64, timeout time.Duration) *AIMDLimit {
+	opts = opts.withDefaults()
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+	return &AIMDLimit{
+		opts:         opts,
+		backoffRatio: backoffRatio,
+		timeout:      timeout,
+		limit:        opts.clamp(float64(opts.Initial)),
+	}
+}
+
+func (a *AIMDLimit) Limit() int {
+	return int(a.limit)
+}
+
+func (a *AIMDLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	if dropped || (a.timeout > 0 && rtt > a.timeout) {
+		if staleDrop(inflight, a.limit) {
+			return int(a.limit)
+		}
+		a.limit = a.opts.clamp(math.Floor(a.limit * a.backoffRatio))
+	} else if !appLimited(inflight, a.limit) {
+		a.limit = a.opts.clamp(a.limit + 1/a.limit)
+	}
+	return int(a.limit)
+}
+
+// VegasLimit 参考TCP Vegas的算法, 以观察到的最小延迟作为无负载延迟, 根据当前延迟估算排队的请求数,
+// 排队少时快速增大上限, 排队多或请求被丢弃时减小上限. 与TCP Vegas相同, 每轮(约limit个请求)调整一次
+type VegasLimit struct {
+	opts      LimitOptions
+	rttNoLoad time.Duration
+	limit     float64
+}
+
+// NewVegasLimit 创建Vegas算法
+func NewVegasLimit(opts LimitOptions) *VegasLimit {
+	opts = opts.withDefaults()
+	return &VegasLimit{
+		opts:  opts,
+		limit: opts.clamp(float64(opts.Initial)),
+	}
+}
+
+func (v *VegasLimit) Limit() int {
+	return int(v.limit)
+}
+
+func (v *VegasLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	if rtt <= 0 {
+		return int(v.limit)
+	}
+	if v.rttNoLoad == 0 || rtt < v.rttNoLoad {
+		v.rttNoLoad = rtt
+		return int(v.limit)
+	}
+	logLimit := math.Max(1, math.Log10(v.limit))
+	if dropped {
+		if staleDrop(inflight, v.limit) {
+			return int(v.limit)
+		}
+		v.limit = v.opts.clamp(v.limit - logLimit/v.limit)
+		return int(v.limit)
+	}
+	if appLimited(inflight, v.limit) {
+		return int(v.limit)
+	}
+	queueSize := math.Ceil(v.limit * (1 - float64(v.rttNoLoad)/float64(rtt)))
+	alpha, beta := 3*logLimit, 6*logLimit
+	var delta float64
+	switch {
+	case queueSize <= logLimit:
+		delta = beta
+	case queueSize < alpha:
+		delta = logLimit
+	case queueSize > beta:
+		delta = -logLimit
+	}
+	v.limit = v.opts.clamp(v.limit + delta/v.limit)
+	return int(v.limit)
+}
+
+// GradientLimit 梯度算法, 比较长期平均延迟与当前延迟的比值, 延迟升高时按比例减小上限,
+// 并允许sqrt(limit)个请求排队以便探测更高的并发. 每个采样只按1/limit的比例调整, 每轮(约limit个请求)调整一次
+type GradientLimit struct {
+	opts      LimitOptions
+	smoothing float64
+	window    float64
+	longRtt   float64
+	limit     float64
+}
+
+// NewGradientLimit 创建梯度算法, smoothing为上限变化的平滑系数, 不在(0,1]内时使用0.2;
+// window为计算长期平均延迟的采样数, 小于等于0时使用600
+func NewGradientLimit(opts LimitOptions, smoothing float64, window int) *GradientLimit {
+	opts = opts.withDefaults()
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if window <= 0 {
+		window = 600
+	}
+	return &GradientLimit{
+		opts:      opts,
+		smoothing: smoothing,
+		window:    float64(window),
+		limit:     opts.clamp(float64(opts.Initial)),
+	}
+}
+
+func (gl *GradientLimit) Limit() int {
+	return int(gl.limit)
+}
+
+func (gl *GradientLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	shortRtt := float64(rtt)
+	if shortRtt <= 0 {
+		return int(gl.limit)
+	}
+	if gl.longRtt == 0 {
+		gl.longRtt = shortRtt
+	} else {
+		gl.longRtt += (shortRtt - gl.longRtt) / gl.window
+	}
+	// 负载下降后长期延迟偏高, 快速向当前延迟靠拢
+	if gl.longRtt/shortRtt > 2 {
+		gl.longRtt *= 0.95
+	}
+	if !dropped && appLimited(inflight, gl.limit) {
+		return int(gl.limit)
+	}
+	gradient := math.Max(0.5, math.Min(1, gl.longRtt/shortRtt))
+	if dropped {
+		if staleDrop(inflight, gl.limit) {
+			return int(gl.limit)
+		}
+		gradient = 0.5
+	}
+	newLimit := gl.limit*gradient + math.Sqrt(gl.limit)
+	gl.limit = gl.opts.clamp(gl.limit + gl.smoothing*(newLimit-gl.limit)/gl.limit)
+	return int(gl.limit)
+}
@@ -1,16 +1,52 @@
 package semaphore
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"github.com/koleter/go-util/list/dlinkedlist"
 	"sync"
+	"time"
 )
 
+var (
+	// ErrClosed 信号量已关闭
+	ErrClosed = errors.New("semaphore is closed")
+	// ErrReleaseExceeded 释放的数量超过了已获取的数量
+	ErrReleaseExceeded = errors.New("release without acquire")
+	// ErrLimitExceeded 请求的数量超过了信号量上限, 永远无法满足
+	ErrLimitExceeded = errors.New("acquire count exceeds semaphore limit")
+)
+
+// Semaphore 带权重的信号量, 等待者按FIFO顺序获得信号量, 请求数量大的等待者不会被后来的小请求饿死
 type Semaphore struct {
-	mu     sync.Mutex
-	cond   *sync.Cond
-	count  int
-	max    int
-	closed bool
+	mu      sync.Mutex
+	count   int
+	max     int
+	closed  bool
+	waiters dlinkedlist.DoublyLinkedList[*waiter]
+
+	acquired uint64        // 累计获得信号量的次数
+	waited   uint64        // 累计需要等待的请求数
+	waitTime time.Duration // 累计等待时长
+}
+
+// Stats 信号量的使用统计
+type Stats struct {
+	Limit    int           // 信号量上限
+	InUse    int           // 已被获取的数量
+	Waiting  int           // 正在等待的请求数
+	Acquired uint64        // 累计获得信号量的次数
+	Waited   uint64        // 累计需要等待的请求数, 包括放弃等待的请求
+	WaitTime time.Duration // 累计等待时长
+}
+
+// waiter 等待信号量的协程
+type waiter struct {
+	n     int
+	start time.Time
+	err   error
+	ready chan struct{} // 获得信号量或信号量关闭时关闭
+	node  *dlinkedlist.Node[*waiter]
 }
 
 // NewSemaphore 创建一个新的信号量实例
@@ -18,31 +54,89 @@ func NewSemaphore(max int) *Semaphore {
 	s := &Semaphore{
 		max: max,
 	}
-	s.cond = sync.NewCond(&s.mu)
 	return s
 }
 
 // Acquire 请求信号量
 func (s *Semaphore) Acquire(cnt int) error {
+	return s.AcquireContext(context.Background(), cnt)
+}
+
+// AcquireTimeout 请求信号量, 超过d时长仍未获得时返回 context.DeadlineExceeded
+func (s *Semaphore) AcquireTimeout(cnt int, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return s.AcquireContext(ctx, cnt)
+}
+
+// AcquireContext 请求信号量, ctx结束时放弃等待并返回ctx.Err(), 返回错误时不会获得任何信号量;
+// 请求的数量超过上限时立即返回 ErrLimitExceeded, 等待期间上限减小到小于请求的数量时同样返回 ErrLimitExceeded
+func (s *Semaphore) AcquireContext(ctx context.Context, cnt int) error {
 	if cnt <= 0 {
 		panic("cnt <= 0")
 	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if s.waiters.Len() == 0 && s.count+cnt <= s.max {
+		s.count += cnt
+		s.acquired++
+		s.mu.Unlock()
+		return nil
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	if cnt > s.max {
+		// 无法满足的请求进入队列会阻塞后面所有的等待者
+		s.mu.Unlock()
+		return ErrLimitExceeded
+	}
+	if err := ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	w := &waiter{n: cnt, start: time.Now(), ready: make(chan struct{})}
+	w.node = s.waiters.PushBack(w)
+	s.waited++
+	s.mu.Unlock()
 
-	for s.count+cnt > s.max {
-		if s.closed {
-			return fmt.Errorf("semaphore is closed")
+	select {
+	case <-w.ready:
+		return w.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.node == nil {
+			// 放弃等待的同时已经获得了信号量
+			return w.err
+		}
+		isHead := s.waiters.Head() == w.node
+		s.removeWaiter(w)
+		if isHead {
+			// 队首的等待者离开后, 后面的等待者可能已经可以获得信号量
+			s.notifyWaiters()
 		}
-		s.cond.Wait()
+		return ctx.Err()
 	}
+}
 
-	s.count += cnt
-	return nil
+// TryAcquire 尝试请求信号量, 数量不足或有其他协程在等待时立即返回false
+func (s *Semaphore) TryAcquire(cnt int) bool {
+	if cnt <= 0 {
+		panic("cnt <= 0")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiters.Len() == 0 && s.count+cnt <= s.max {
+		s.count += cnt
+		s.acquired++
+		return true
+	}
+	return false
 }
 
-// Release 释放信号量
-func (s *Semaphore) Release(cnt int) {
+// Release 释放信号量, 释放的数量超过已获取的数量时返回 ErrReleaseExceeded 且不做任何改变
+func (s *Semaphore) Release(cnt int) error {
 	if cnt <= 0 {
 		panic("cnt <= 0")
 	}
@@ -50,18 +144,120 @@ func (s *Semaphore) Release(cnt int) {
 	defer s.mu.Unlock()
 
 	if s.count < cnt {
-		fmt.Errorf("release without acquire")
+		return ErrReleaseExceeded
 	}
 
 	s.count -= cnt
-	s.cond.Signal()
+	s.notifyWaiters()
+	return nil
+}
+
+// SetLimit 修改信号量上限, 上限增大时唤醒能够获得信号量的等待者,
+// 上限减小时已获取的信号量不受影响, 在释放到新上限以下之前新的请求都需要等待;
+// 请求的数量超过新上限的等待者返回 ErrLimitExceeded
+func (s *Semaphore) SetLimit(max int) {
+	if max < 0 {
+		panic("max < 0")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max = max
+	for n := s.waiters.Head(); n != nil; {
+		next := n.Next()
+		if w := n.Value; w.n > max {
+			s.failWaiter(w, ErrLimitExceeded)
+		}
+		n = next
+	}
+	s.notifyWaiters()
 }
 
-// Close 关闭信号量
+// Limit 信号量上限
+func (s *Semaphore) Limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.max
+}
+
+// Available 当前可以获取的数量, 上限减小后已获取数量超过上限时为0
+func (s *Semaphore) Available() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count >= s.max {
+		return 0
+	}
+	return s.max - s.count
+}
+
+// InUse 已被获取的数量
+func (s *Semaphore) InUse() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Waiting 正在等待的请求数
+func (s *Semaphore) Waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters.Len()
+}
+
+// Stats 返回使用统计, 累计等待时长包括仍在等待的请求已经等待的时长
+func (s *Semaphore) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{
+		Limit:    s.max,
+		InUse:    s.count,
+		Waiting:  s.waiters.Len(),
+		Acquired: s.acquired,
+		Waited:   s.waited,
+		WaitTime: s.waitTime,
+	}
+	now := time.Now()
+	for n := s.waiters.Head(); n != nil; n = n.Next() {
+		stats.WaitTime += now.Sub(n.Value.start)
+	}
+	return stats
+}
+
+// Close 关闭信号量, 唤醒所有等待者并返回 ErrClosed
 func (s *Semaphore) Close() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.closed = true
-	s.cond.Broadcast()
+	for head := s.waiters.Head(); head != nil; head = s.waiters.Head() {
+		s.failWaiter(head.Value, ErrClosed)
+	}
+}
+
+// notifyWaiters 按FIFO顺序唤醒能够获得信号量的等待者, 调用前需要持有 s.mu
+func (s *Semaphore) notifyWaiters() {
+	for head := s.waiters.Head(); head != nil; head = s.waiters.Head() {
+		w := head.Value
+		if s.count+w.n > s.max {
+			// 队首的请求无法满足时不唤醒后面的等待者, 避免大请求被饿死
+			return
+		}
+		s.count += w.n
+		s.acquired++
+		s.removeWaiter(w)
+		close(w.ready)
+	}
+}
+
+// failWaiter 将等待者移出队列并以err唤醒, 调用前需要持有 s.mu
+func (s *Semaphore) failWaiter(w *waiter, err error) {
+	s.removeWaiter(w)
+	w.err = err
+	close(w.ready)
+}
+
+// removeWaiter 将等待者移出队列并累计等待时长, 调用前需要持有 s.mu
+func (s *Semaphore) removeWaiter(w *waiter) {
+	s.waiters.Remove(w.node)
+	w.node = nil
+	s.waitTime += time.Since(w.start)
 }
@@ -1,6 +1,8 @@
 package semaphore_test
 
 import (
+	"context"
+	"errors"
 	"github.com/koleter/go-util/concurrency/semaphore"
 	"testing"
 	"time"
@@ -54,3 +56,232 @@ func TestConcurrentAcquireRelease(t *testing.T) {
 		}
 	}
 }
+
+// TestReleaseExceeded 测试释放超过已获取数量时返回错误
+func TestReleaseExceeded(t *testing.T) {
+	sem := semaphore.NewSemaphore(2)
+	if err := sem.Acquire(1); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := sem.Release(2); !errors.Is(err, semaphore.ErrReleaseExceeded) {
+		t.Errorf("expected ErrReleaseExceeded, got %v", err)
+	}
+	if err := sem.Release(1); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+}
+
+// TestTryAcquire 测试 TryAcquire 不会阻塞
+func TestTryAcquire(t *testing.T) {
+	sem := semaphore.NewSemaphore(3)
+	if !sem.TryAcquire(2) {
+		t.Fatal("TryAcquire(2) should succeed")
+	}
+	if sem.TryAcquire(2) {
+		t.Fatal("TryAcquire(2) should fail")
+	}
+	if !sem.TryAcquire(1) {
+		t.Fatal("TryAcquire(1) should succeed")
+	}
+}
+
+// TestAcquireContext 测试 ctx 结束时放弃等待
+func TestAcquireContext(t *testing.T) {
+	sem := semaphore.NewSemaphore(1)
+	if err := sem.Acquire(1); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if err := sem.AcquireContext(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := sem.AcquireTimeout(1, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	sem.Release(1)
+	if !sem.TryAcquire(1) {
+		t.Error("cancelled waiters should not hold the semaphore")
+	}
+}
+
+// TestFIFOWeighted 测试带权重的请求按FIFO顺序获得信号量, 大请求不会被小请求饿死
+func TestFIFOWeighted(t *testing.T) {
+	sem := semaphore.NewSemaphore(3)
+	if err := sem.Acquire(2); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	order := make(chan int, 2)
+	go func() {
+		sem.Acquire(3)
+		order <- 3
+		sem.Release(3)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		sem.Acquire(1)
+		order <- 1
+		sem.Release(1)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// 剩余的1个信号量不能被后来的小请求拿走
+	if sem.TryAcquire(1) {
+		t.Fatal("TryAcquire should not jump the queue")
+	}
+	sem.Release(2)
+	if first, second := <-order, <-order; first != 3 || second != 1 {
+		t.Errorf("expected order 3,1, got %d,%d", first, second)
+	}
+}
+
+// TestCancelHeadWakesNext 测试队首等待者放弃后唤醒后面的等待者
+func TestCancelHeadWakesNext(t *testing.T) {
+	sem := semaphore.NewSemaphore(2)
+	if err := sem.Acquire(1); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	headErr := make(chan error)
+	go func() {
+		headErr <- sem.AcquireContext(ctx, 2)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	acquired := make(chan struct{})
+	go func() {
+		sem.Acquire(1)
+		close(acquired)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-headErr
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Error("waiter behind cancelled head was not woken")
+	}
+}
+
+// TestCloseWakesWaiters 测试关闭信号量时唤醒等待者
+func TestCloseWakesWaiters(t *testing.T) {
+	sem := semaphore.NewSemaphore(1)
+	sem.Acquire(1)
+	errCh := make(chan error)
+	go func() {
+		errCh <- sem.Acquire(1)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sem.Close()
+	if err := <-errCh; !errors.Is(err, semaphore.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+// TestSetLimit 测试运行时修改上限
+func TestSetLimit(t *testing.T) {
+	sem := semaphore.NewSemaphore(1)
+	sem.Acquire(1)
+	acquired := make(chan struct{})
+	go func() {
+		sem.Acquire(1)
+		close(acquired)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if sem.Waiting() != 1 {
+		t.Fatalf("expected 1 waiting, got %d", sem.Waiting())
+	}
+	sem.SetLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not woken when the limit grew")
+	}
+	if sem.InUse() != 2 || sem.Available() != 0 {
+		t.Errorf("expected 2 in use and 0 available, got %d and %d", sem.InUse(), sem.Available())
+	}
+
+	sem.SetLimit(1)
+	if sem.TryAcquire(1) {
+		t.Fatal("TryAcquire should fail until the count drains below the new limit")
+	}
+	sem.Release(1)
+	if sem.TryAcquire(1) {
+		t.Fatal("TryAcquire should fail until the count drains below the new limit")
+	}
+	sem.Release(1)
+	if !sem.TryAcquire(1) {
+		t.Error("TryAcquire should succeed after the count drained")
+	}
+}
+
+// TestAcquireExceedsLimit 测试超过上限的请求立即失败, 不会阻塞后面的请求
+func TestAcquireExceedsLimit(t *testing.T) {
+	sem := semaphore.NewSemaphore(2)
+	if err := sem.Acquire(3); !errors.Is(err, semaphore.ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+	if sem.Waiting() != 0 {
+		t.Fatalf("expected 0 waiting, got %d", sem.Waiting())
+	}
+	if err := sem.AcquireTimeout(1, 100*time.Millisecond); err != nil {
+		t.Fatalf("Acquire(1) on an idle semaphore failed: %v", err)
+	}
+}
+
+// TestSetLimitFailsWaitersExceedingLimit 测试上限减小后无法满足的等待者返回错误, 后面的等待者继续获得信号量
+func TestSetLimitFailsWaitersExceedingLimit(t *testing.T) {
+	sem := semaphore.NewSemaphore(4)
+	sem.Acquire(4)
+	bigErr := make(chan error, 1)
+	go func() {
+		bigErr <- sem.Acquire(3)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	smallErr := make(chan error, 1)
+	go func() {
+		smallErr <- sem.Acquire(1)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	sem.SetLimit(2)
+	select {
+	case err := <-bigErr:
+		if !errors.Is(err, semaphore.ErrLimitExceeded) {
+			t.Fatalf("expected ErrLimitExceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter exceeding the new limit was not failed")
+	}
+	sem.Release(4)
+	select {
+	case err := <-smallErr:
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter behind the failed one was not woken")
+	}
+}
+
+// TestStats 测试使用统计
+func TestStats(t *testing.T) {
+	sem := semaphore.NewSemaphore(1)
+	sem.Acquire(1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		sem.Release(1)
+	}()
+	sem.Acquire(1)
+	stats := sem.Stats()
+	if stats.Limit != 1 || stats.InUse != 1 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.Acquired != 2 || stats.Waited != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.WaitTime < 20*time.Millisecond {
+		t.Errorf("expected wait time >= 20ms, got %v", stats.WaitTime)
+	}
+}
@@ -0,0 +1,70 @@
+package g
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// _Gdead is the runtime status of a goroutine that has exited and whose g is waiting to be reused.
+const _Gdead = 6
+
+var (
+	layoutOnce sync.Once
+	// goidOffset is the offset of runtime.g.goid.
+	goidOffset uintptr
+	// statusOffset is the offset of runtime.g.atomicstatus.
+	statusOffset uintptr
+	// layoutErr is set if the offsets cannot be resolved.
+	layoutErr string
+)
+
+// loadLayout resolves the offsets of runtime.g on first use rather than at package init,
+// so importing the package does not depend on the runtime layout. It panics if the layout is not supported.
+func loadLayout() {
+	layoutOnce.Do(func() {
+		gt := getgt()
+		if gt == nil {
+			layoutErr = "runtime.g is not found"
+			return
+		}
+		goid, ok := gt.FieldByName("goid")
+		if !ok {
+			layoutErr = "runtime.g has no field goid"
+			return
+		}
+		status, ok := gt.FieldByName("atomicstatus")
+		if !ok {
+			layoutErr = "runtime.g has no field atomicstatus"
+			return
+		}
+		goidOffset, statusOffset = goid.Offset, status.Offset
+	})
+	if layoutErr != "" {
+		panic(layoutErr)
+	}
+}
+
+// Goid returns the id of the current goroutine, ids are never reused during the lifetime of the process.
+func Goid() uint64 {
+	return goidOf(getgp())
+}
+
+// goidOf returns the id of the goroutine whose runtime.g is gp.
+func goidOf(gp unsafe.Pointer) uint64 {
+	loadLayout()
+	return atomic.LoadUint64((*uint64)(unsafe.Add(gp, goidOffset)))
+}
+
+// statusOf returns the status of the goroutine whose runtime.g is gp, without the scan bit.
+func statusOf(gp unsafe.Pointer) uint32 {
+	const _Gscan = 0x1000
+	loadLayout()
+	return atomic.LoadUint32((*uint32)(unsafe.Add(gp, statusOffset))) &^ _Gscan
+}
+
+// alive reports whether the goroutine with the given id and runtime.g is still running.
+// The runtime never frees a g, an exited goroutine's g is either dead or reused by a goroutine with a new id.
+func alive(gp unsafe.Pointer, goid uint64) bool {
+	return goidOf(gp) == goid && statusOf(gp) != _Gdead
+}
@@ -0,0 +1,43 @@
+package g
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stackGoid parses the goroutine id from the header of runtime.Stack.
+func stackGoid() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := strings.Fields(string(buf))
+	id, _ := strconv.ParseUint(fields[1], 10, 64)
+	return id
+}
+
+func TestGoid(t *testing.T) {
+	goid := Goid()
+	assert.Equal(t, stackGoid(), goid)
+	runTest(t, func() {
+		assert.Equal(t, stackGoid(), Goid())
+		assert.NotEqual(t, goid, Goid())
+	})
+}
+
+func TestAlive(t *testing.T) {
+	assert.True(t, alive(getgp(), Goid()))
+	var gp unsafe.Pointer
+	var goid uint64
+	runTest(t, func() {
+		gp, goid = getgp(), Goid()
+	})
+	// runTest only waits for the function, the goroutine may still be exiting
+	assert.Eventually(t, func() bool {
+		return !alive(gp, goid)
+	}, time.Second, time.Millisecond)
+}
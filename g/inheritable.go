@@ -0,0 +1,87 @@
+package g
+
+// InheritableThreadLocal is a ThreadLocal whose values are captured by Capture and restored in another goroutine,
+// which passes values such as request ids to goroutines started by Go or tasks submitted to a pool.
+type InheritableThreadLocal[T any] struct {
+	initial func() T
+}
+
+// NewInheritableThreadLocal creates an InheritableThreadLocal whose initial value is the zero value of T.
+func NewInheritableThreadLocal[T any]() *InheritableThreadLocal[T] {
+	return &InheritableThreadLocal[T]{}
+}
+
+// NewInheritableThreadLocalWithInitial creates an InheritableThreadLocal whose initial value is returned by supplier.
+func NewInheritableThreadLocalWithInitial[T any](supplier func() T) *InheritableThreadLocal[T] {
+	return &InheritableThreadLocal[T]{initial: supplier}
+}
+
+// Get returns the value of the current goroutine.
+func (t *InheritableThreadLocal[T]) Get() T {
+	return getLocal(t, true, t.initial)
+}
+
+// Set sets the value of the current goroutine.
+func (t *InheritableThreadLocal[T]) Set(v T) {
+	setLocal(t, true, v)
+}
+
+// Remove removes the value of the current goroutine, the next Get returns the initial value.
+func (t *InheritableThreadLocal[T]) Remove() {
+	removeLocal(t, true)
+}
+
+// Snapshot holds the InheritableThreadLocal values of a goroutine at the time Capture is called.
+// Values are copied shallowly, a child sees changes made to the objects they point to but not calls to Set.
+type Snapshot struct {
+	values map[any]any
+}
+
+// Capture returns the InheritableThreadLocal values of the current goroutine.
+func Capture() Snapshot {
+	th := currentThread(false)
+	if th == nil || len(th.inheritable) == 0 {
+		return Snapshot{}
+	}
+	return Snapshot{values: copyValues(th.inheritable)}
+}
+
+// Run calls fn with the InheritableThreadLocal values of the current goroutine replaced by the snapshot,
+// the previous values are restored after fn returns, so values set by fn do not leak to later calls.
+func (s Snapshot) Run(fn func()) {
+	var prev map[any]any
+	if th := currentThread(len(s.values) > 0); th != nil {
+		prev = th.inheritable
+		th.inheritable = copyValues(s.values)
+	}
+	defer func() {
+		// fn may have removed or recreated the thread, look it up again
+		if th := currentThread(len(prev) > 0); th != nil {
+			th.inheritable = prev
+			th.release()
+		}
+	}()
+	fn()
+}
+
+// Wrap captures the InheritableThreadLocal values of the current goroutine and returns a function
+// that calls fn with these values in the goroutine it is called from.
+func Wrap(fn func()) func() {
+	s := Capture()
+	return func() {
+		s.Run(fn)
+	}
+}
+
+// Go starts a goroutine that calls fn with the InheritableThreadLocal values of the current goroutine.
+func Go(fn func()) {
+	go Wrap(fn)()
+}
+
+func copyValues(values map[any]any) map[any]any {
+	res := make(map[any]any, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package g
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInheritableThreadLocal_Go(t *testing.T) {
+	requestID := NewInheritableThreadLocal[string]()
+	local := NewThreadLocal[string]()
+	requestID.Set("req-1")
+	local.Set("not inherited")
+	defer requestID.Remove()
+	defer local.Remove()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Go(func() {
+		defer wg.Done()
+		assert.Equal(t, "req-1", requestID.Get())
+		assert.Equal(t, "", local.Get())
+		// Changes in the child do not affect the parent
+		requestID.Set("req-2")
+	})
+	wg.Wait()
+	assert.Equal(t, "req-1", requestID.Get())
+}
+
+func TestInheritableThreadLocal_not_inherited_by_go_statement(t *testing.T) {
+	requestID := NewInheritableThreadLocal[string]()
+	requestID.Set("req-1")
+	defer requestID.Remove()
+	runTest(t, func() {
+		assert.Equal(t, "", requestID.Get())
+	})
+}
+
+func TestSnapshot_Run_restores_previous_values(t *testing.T) {
+	requestID := NewInheritableThreadLocal[string]()
+	requestID.Set("req-1")
+	snapshot := Capture()
+	requestID.Set("req-2")
+
+	snapshot.Run(func() {
+		assert.Equal(t, "req-1", requestID.Get())
+		requestID.Remove()
+		assert.Equal(t, "", requestID.Get())
+	})
+	assert.Equal(t, "req-2", requestID.Get())
+
+	requestID.Remove()
+	// Values set by fn do not leak once Run returns
+	Snapshot{}.Run(func() {
+		requestID.Set("leak")
+	})
+	assert.Equal(t, "", requestID.Get())
+	_, ok := threads.Load(Goid())
+	assert.False(t, ok)
+}
+
+func TestWrap(t *testing.T) {
+	requestID := NewInheritableThreadLocalWithInitial(func() string { return "none" })
+	requestID.Set("req-1")
+	fn := Wrap(func() {
+		assert.Equal(t, "req-1", requestID.Get())
+	})
+	requestID.Remove()
+	runTest(t, fn)
+	assert.Equal(t, "none", requestID.Get())
+	requestID.Remove()
+}
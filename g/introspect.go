@@ -0,0 +1,336 @@
+package g
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Status is the runtime status of a goroutine.
+type Status uint32
+
+// Goroutine statuses, the values are the same as the runtime's _Gidle, _Grunnable and so on.
+const (
+	StatusIdle      Status = 0
+	StatusRunnable  Status = 1
+	StatusRunning   Status = 2
+	StatusSyscall   Status = 3
+	StatusWaiting   Status = 4
+	StatusDead      Status = _Gdead
+	StatusCopyStack Status = 8
+	StatusPreempted Status = 9
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusIdle:
+		return "idle"
+	case StatusRunnable:
+		return "runnable"
+	case StatusRunning:
+		return "running"
+	case StatusSyscall:
+		return "syscall"
+	case StatusWaiting:
+		return "waiting"
+	case StatusDead:
+		return "dead"
+	case StatusCopyStack:
+		return "copystack"
+	case StatusPreempted:
+		return "preempted"
+	default:
+		return "unknown(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// Info holds fields read from a runtime.g.
+type Info struct {
+	ID uint64
+	// ParentID is the id of the goroutine that created this goroutine, zero if the runtime does not record it.
+	ParentID uint64
+	Status   Status
+	// WaitReason is the raw runtime.waitReason, meaningful only when Status is StatusWaiting.
+	// Its names differ between Go versions, use the State of AllGoroutines for a readable reason.
+	WaitReason uint8
+	// StartPC is the pc of the goroutine function.
+	StartPC uintptr
+	// CreatedPC is the pc of the go statement that created this goroutine.
+	CreatedPC uintptr
+	// Labels are the pprof labels set by pprof.SetGoroutineLabels or pprof.Do.
+	Labels map[string]string
+}
+
+// StartFunction returns the name of the goroutine function.
+func (i Info) StartFunction() string {
+	return funcName(i.StartPC)
+}
+
+// CreatedBy returns the name of the function that created this goroutine.
+func (i Info) CreatedBy() string {
+	return funcName(i.CreatedPC)
+}
+
+func funcName(pc uintptr) string {
+	if f := runtime.FuncForPC(pc); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// gField is a field of runtime.g that may not exist in every Go version.
+type gField struct {
+	offset uintptr
+	ok     bool
+}
+
+func optionalField(gt reflect.Type, name string) gField {
+	f, ok := gt.FieldByName(name)
+	return gField{offset: f.Offset, ok: ok}
+}
+
+var (
+	infoOnce        sync.Once
+	parentGoidField gField
+	waitReasonField gField
+	startPCField    gField
+	goPCField       gField
+	labelsField     gField
+	// labelMapType is the type runtime.g.labels points to, nil if runtime/pprof is not linked in, in which case no labels can be set.
+	labelMapType reflect.Type
+)
+
+// loadInfoFields resolves the optional fields of runtime.g on first use rather than at package init.
+func loadInfoFields() {
+	infoOnce.Do(func() {
+		gt := getgt()
+		if gt == nil {
+			return
+		}
+		parentGoidField = optionalField(gt, "parentGoid")
+		waitReasonField = optionalField(gt, "waitreason")
+		startPCField = optionalField(gt, "startpc")
+		goPCField = optionalField(gt, "gopc")
+		labelsField = optionalField(gt, "labels")
+		labelMapType = typeByString("pprof.labelMap")
+	})
+}
+
+// Current returns the Info of the current goroutine.
+func Current() Info {
+	return InfoOf(getgp())
+}
+
+// InfoOf returns the Info of the goroutine whose runtime.g is gp, for example the owner recorded by G.
+// Fields of another goroutine are read without synchronization and may be stale.
+func InfoOf(gp unsafe.Pointer) Info {
+	loadInfoFields()
+	info := Info{
+		ID:     goidOf(gp),
+		Status: Status(statusOf(gp)),
+	}
+	if parentGoidField.ok {
+		info.ParentID = atomic.LoadUint64((*uint64)(unsafe.Add(gp, parentGoidField.offset)))
+	}
+	if waitReasonField.ok {
+		info.WaitReason = *(*uint8)(unsafe.Add(gp, waitReasonField.offset))
+	}
+	if startPCField.ok {
+		info.StartPC = *(*uintptr)(unsafe.Add(gp, startPCField.offset))
+	}
+	if goPCField.ok {
+		info.CreatedPC = *(*uintptr)(unsafe.Add(gp, goPCField.offset))
+	}
+	if labelsField.ok && labelMapType != nil {
+		labels := atomic.LoadPointer((*unsafe.Pointer)(unsafe.Add(gp, labelsField.offset)))
+		if labels != nil {
+			info.Labels = map[string]string{}
+			collectLabels(reflect.ValueOf(packEface(labelMapType, labels)), info.Labels)
+		}
+	}
+	return info
+}
+
+// collectLabels collects the key value pairs of a pprof label map, which is a map[string]string
+// or a struct holding a list of {key, value string} depending on the Go version.
+func collectLabels(v reflect.Value, labels map[string]string) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String {
+			iter := v.MapRange()
+			for iter.Next() {
+				labels[iter.Key().String()] = iter.Value().String()
+			}
+		}
+	case reflect.Struct:
+		if v.NumField() == 2 && v.Field(0).Kind() == reflect.String && v.Field(1).Kind() == reflect.String {
+			labels[v.Field(0).String()] = v.Field(1).String()
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			collectLabels(v.Field(i), labels)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectLabels(v.Index(i), labels)
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectLabels(v.Elem(), labels)
+		}
+	}
+}
+
+// Frame is a stack frame of a goroutine.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Goroutine is a goroutine parsed from runtime.Stack.
+type Goroutine struct {
+	ID uint64
+	// State is the status or the wait reason, such as "running", "chan receive" or "sync.Mutex.Lock".
+	State string
+	// WaitDuration is how long the goroutine has been blocked, the runtime only reports it in whole minutes after one minute.
+	WaitDuration   time.Duration
+	LockedToThread bool
+	Frames         []Frame
+	// CreatedBy is the frame of the go statement that created the goroutine, its Function is empty for the main goroutine.
+	CreatedBy Frame
+	// ParentID is the id of the goroutine that created this goroutine, zero if unknown.
+	ParentID uint64
+}
+
+// HasFunction reports whether a frame of the goroutine is in the named function,
+// the closures of the function such as name.func1 are also matched.
+func (g *Goroutine) HasFunction(name string) bool {
+	for _, f := range g.Frames {
+		if f.Function == name || strings.HasPrefix(f.Function, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// AllGoroutines returns all goroutines parsed from runtime.Stack, which stops the world while collecting.
+func AllGoroutines() []Goroutine {
+	return parseGoroutines(allStacks())
+}
+
+// GoroutinesWithFunction returns the goroutines whose stack has a frame in the named function.
+func GoroutinesWithFunction(name string) []Goroutine {
+	var res []Goroutine
+	for _, gr := range AllGoroutines() {
+		if gr.HasFunction(name) {
+			res = append(res, gr)
+		}
+	}
+	return res
+}
+
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// parseGoroutines parses the output of runtime.Stack.
+func parseGoroutines(stack []byte) []Goroutine {
+	var res []Goroutine
+	var cur *Goroutine
+	var fn string
+	var createdBy bool // fn is the function of the "created by" line
+	scanner := bufio.NewScanner(bytes.NewReader(stack))
+	scanner.Buffer(make([]byte, 0, 4096), len(stack)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			res = append(res, parseHeader(line))
+			cur = &res[len(res)-1]
+			fn, createdBy = "", false
+		case cur == nil || line == "":
+		case strings.HasPrefix(line, "\t"):
+			file, lineNo := parseLocation(line)
+			if fn == "" {
+				continue
+			}
+			if createdBy {
+				cur.CreatedBy.File, cur.CreatedBy.Line = file, lineNo
+			} else {
+				cur.Frames = append(cur.Frames, Frame{Function: fn, File: file, Line: lineNo})
+			}
+			fn = ""
+		case strings.HasPrefix(line, "created by "):
+			fn = strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(fn, " in goroutine "); i >= 0 {
+				cur.ParentID, _ = strconv.ParseUint(fn[i+len(" in goroutine "):], 10, 64)
+				fn = fn[:i]
+			}
+			cur.CreatedBy.Function = fn
+			createdBy = true
+		default:
+			// function line, such as "main.main()" or "sync.(*Mutex).Lock(...)"
+			if i := strings.LastIndex(line, "("); i > 0 {
+				fn = line[:i]
+			} else {
+				fn = ""
+			}
+		}
+	}
+	return res
+}
+
+// parseHeader parses a line such as "goroutine 7 [chan receive, 3 minutes, locked to thread]:".
+func parseHeader(line string) Goroutine {
+	var gr Goroutine
+	line = strings.TrimPrefix(line, "goroutine ")
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		gr.ID, _ = strconv.ParseUint(line[:i], 10, 64)
+	}
+	start, end := strings.IndexByte(line, '['), strings.LastIndexByte(line, ']')
+	if start < 0 || end < start {
+		return gr
+	}
+	for i, part := range strings.Split(line[start+1:end], ", ") {
+		switch {
+		case i == 0:
+			gr.State = part
+		case part == "locked to thread":
+			gr.LockedToThread = true
+		case strings.HasSuffix(part, " minutes"):
+			if m, err := strconv.Atoi(strings.TrimSuffix(part, " minutes")); err == nil {
+				gr.WaitDuration = time.Duration(m) * time.Minute
+			}
+		}
+	}
+	return gr
+}
+
+// parseLocation parses a line such as "\t/path/to/file.go:12 +0x1d".
+func parseLocation(line string) (string, int) {
+	line = strings.TrimPrefix(line, "\t")
+	if i := strings.LastIndex(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return line, 0
+	}
+	n, _ := strconv.Atoi(line[i+1:])
+	return line[:i], n
+}
@@ -0,0 +1,129 @@
+package g
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrent(t *testing.T) {
+	parent := Goid()
+	runTest(t, func() {
+		info := Current()
+		assert.Equal(t, Goid(), info.ID)
+		assert.Equal(t, StatusRunning, info.Status)
+		assert.Equal(t, "running", info.Status.String())
+		assert.Equal(t, parent, info.ParentID)
+		assert.Equal(t, "github.com/koleter/go-util/g.runTest.func1", info.StartFunction())
+		assert.Equal(t, "github.com/koleter/go-util/g.runTest", info.CreatedBy())
+		assert.Nil(t, info.Labels)
+	})
+}
+
+func TestCurrent_labels(t *testing.T) {
+	pprof.Do(context.Background(), pprof.Labels("request", "r1", "user", "u1"), func(ctx context.Context) {
+		assert.Equal(t, map[string]string{"request": "r1", "user": "u1"}, Current().Labels)
+	})
+	assert.Nil(t, Current().Labels)
+}
+
+func TestInfoOf_waiting(t *testing.T) {
+	var gp unsafe.Pointer
+	ready := make(chan struct{})
+	block := make(chan struct{})
+	go func() {
+		gp = getgp()
+		close(ready)
+		<-block
+	}()
+	<-ready
+	assert.Eventually(t, func() bool {
+		return InfoOf(gp).Status == StatusWaiting
+	}, time.Second, time.Millisecond)
+	assert.NotZero(t, InfoOf(gp).WaitReason)
+	close(block)
+}
+
+func blockOnChannel(ch chan struct{}, wg *sync.WaitGroup) {
+	wg.Done()
+	<-ch
+}
+
+func TestAllGoroutines(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go blockOnChannel(ch, &wg)
+	go blockOnChannel(ch, &wg)
+	wg.Wait()
+
+	var blocked []Goroutine
+	assert.Eventually(t, func() bool {
+		blocked = GoroutinesWithFunction("github.com/koleter/go-util/g.blockOnChannel")
+		return len(blocked) == 2 && blocked[0].State == "chan receive" && blocked[1].State == "chan receive"
+	}, time.Second, time.Millisecond)
+	gr := blocked[0]
+	assert.NotZero(t, gr.ID)
+	assert.Equal(t, Goid(), gr.ParentID)
+	assert.Equal(t, "github.com/koleter/go-util/g.TestAllGoroutines", gr.CreatedBy.Function)
+	assert.True(t, strings.HasSuffix(gr.CreatedBy.File, "introspect_test.go"))
+	last := gr.Frames[len(gr.Frames)-1]
+	assert.Equal(t, "github.com/koleter/go-util/g.blockOnChannel", last.Function)
+	assert.True(t, strings.HasSuffix(last.File, "introspect_test.go"))
+	assert.Greater(t, last.Line, 0)
+
+	var self *Goroutine
+	all := AllGoroutines()
+	for i := range all {
+		if all[i].ID == Goid() {
+			self = &all[i]
+		}
+	}
+	if assert.NotNil(t, self) {
+		assert.Equal(t, "running", self.State)
+		assert.True(t, self.HasFunction("github.com/koleter/go-util/g.TestAllGoroutines"))
+	}
+}
+
+func TestParseGoroutines(t *testing.T) {
+	stack := `goroutine 1 [running, locked to thread]:
+main.main()
+	/tmp/main.go:3 +0x11d
+
+goroutine 7 [sync.Mutex.Lock, 5 minutes]:
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+main.main.func1()
+	/tmp/main.go:3 +0x2c
+created by main.main in goroutine 1
+	/tmp/main.go:3 +0x8b
+`
+	gs := parseGoroutines([]byte(stack))
+	assert.Len(t, gs, 2)
+	assert.Equal(t, Goroutine{
+		ID:             1,
+		State:          "running",
+		LockedToThread: true,
+		Frames:         []Frame{{Function: "main.main", File: "/tmp/main.go", Line: 3}},
+	}, gs[0])
+	assert.Equal(t, Goroutine{
+		ID:           7,
+		State:        "sync.Mutex.Lock",
+		WaitDuration: 5 * time.Minute,
+		Frames: []Frame{
+			{Function: "sync.(*Mutex).Lock", File: "/usr/local/go/src/sync/mutex.go", Line: 46},
+			{Function: "main.main.func1", File: "/tmp/main.go", Line: 3},
+		},
+		CreatedBy: Frame{Function: "main.main", File: "/tmp/main.go", Line: 3},
+		ParentID:  1,
+	}, gs[1])
+	assert.True(t, gs[1].HasFunction("main.main"))
+	assert.False(t, gs[1].HasFunction("main.ma"))
+}
@@ -0,0 +1,200 @@
+package g
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ThreadLocal provides goroutine-local variables, each goroutine that accesses one has its own independent value.
+// Values of exited goroutines are released automatically by a sweep that runs after each garbage collection,
+// at most once per sweepInterval, and after every minSweep goroutines that set a value. So a value is retained
+// until the first garbage collection at least sweepInterval after the last sweep, and the runtime forces a
+// garbage collection at least every two minutes. Calling Remove when a value is no longer needed releases it immediately.
+type ThreadLocal[T any] struct {
+	initial func() T
+}
+
+// thread holds the goroutine-local values of one goroutine, it is only accessed by its owner goroutine.
+type thread struct {
+	gp          unsafe.Pointer
+	goid        uint64
+	values      map[any]any
+	inheritable map[any]any // values of InheritableThreadLocal
+}
+
+const (
+	// minSweep is the minimum number of threads created between two sweeps.
+	minSweep = 1024
+	// sweepInterval is the minimum time between two sweeps triggered by garbage collection.
+	sweepInterval = time.Second
+)
+
+var (
+	// threads maps goroutine id to *thread.
+	threads sync.Map
+	// created is the number of threads created since the last sweep.
+	created   int64
+	nextSweep int64 = minSweep
+	sweepMu   sync.Mutex
+	// lastSweep is the time of the last sweep in unix nanoseconds.
+	lastSweep int64
+	// gcArmed is 1 while a gcSentinel is waiting for the next garbage collection.
+	gcArmed int32
+)
+
+// NewThreadLocal creates a ThreadLocal whose initial value is the zero value of T.
+func NewThreadLocal[T any]() *ThreadLocal[T] {
+	return &ThreadLocal[T]{}
+}
+
+// NewThreadLocalWithInitial creates a ThreadLocal whose initial value is returned by supplier,
+// supplier is called the first time a goroutine calls Get without calling Set before.
+func NewThreadLocalWithInitial[T any](supplier func() T) *ThreadLocal[T] {
+	return &ThreadLocal[T]{initial: supplier}
+}
+
+// Get returns the value of the current goroutine.
+func (t *ThreadLocal[T]) Get() T {
+	return getLocal(t, false, t.initial)
+}
+
+// Set sets the value of the current goroutine.
+func (t *ThreadLocal[T]) Set(v T) {
+	setLocal(t, false, v)
+}
+
+// Remove removes the value of the current goroutine, the next Get returns the initial value.
+func (t *ThreadLocal[T]) Remove() {
+	removeLocal(t, false)
+}
+
+// getLocal returns the value of key in the current goroutine, initializes it by initial if it is not set.
+func getLocal[T any](key any, inheritable bool, initial func() T) T {
+	if th := currentThread(false); th != nil {
+		if v, ok := th.store(inheritable)[key]; ok {
+			return v.(T)
+		}
+	}
+	var v T
+	if initial != nil {
+		v = initial()
+		setLocal(key, inheritable, v)
+	}
+	return v
+}
+
+func setLocal(key any, inheritable bool, v any) {
+	th := currentThread(true)
+	if inheritable && th.inheritable == nil {
+		th.inheritable = map[any]any{}
+	}
+	th.store(inheritable)[key] = v
+}
+
+func removeLocal(key any, inheritable bool) {
+	th := currentThread(false)
+	if th == nil {
+		return
+	}
+	delete(th.store(inheritable), key)
+	th.release()
+}
+
+// store returns the values of ThreadLocal or InheritableThreadLocal.
+func (th *thread) store(inheritable bool) map[any]any {
+	if inheritable {
+		return th.inheritable
+	}
+	return th.values
+}
+
+// release removes the thread if it holds no values.
+func (th *thread) release() {
+	if len(th.values) == 0 && len(th.inheritable) == 0 {
+		threads.Delete(th.goid)
+	}
+}
+
+// currentThread returns the thread of the current goroutine, creates it if create is true.
+func currentThread(create bool) *thread {
+	gp := getgp()
+	goid := goidOf(gp)
+	if th, ok := threads.Load(goid); ok {
+		return th.(*thread)
+	}
+	if !create {
+		return nil
+	}
+	th := &thread{gp: gp, goid: goid, values: map[any]any{}}
+	threads.Store(goid, th)
+	armGCSweep()
+	if atomic.AddInt64(&created, 1) >= atomic.LoadInt64(&nextSweep) {
+		sweep()
+	}
+	return th
+}
+
+// gcSentinel is an unreachable object whose finalizer runs after the next garbage collection.
+// It holds a pointer so that it is not batched by the tiny allocator, whose blocks may never be finalized.
+type gcSentinel struct {
+	_ *int
+}
+
+// armGCSweep makes the next garbage collection sweep the threads of exited goroutines.
+func armGCSweep() {
+	if atomic.CompareAndSwapInt32(&gcArmed, 0, 1) {
+		runtime.SetFinalizer(&gcSentinel{}, sweepOnGC)
+	}
+}
+
+// sweepOnGC runs on the finalizer goroutine, so the sweep itself runs in a new goroutine to not delay other finalizers.
+func sweepOnGC(*gcSentinel) {
+	go func() {
+		live := int64(-1)
+		if time.Now().UnixNano()-atomic.LoadInt64(&lastSweep) >= int64(sweepInterval) {
+			sweepMu.Lock()
+			live = sweepLocked()
+			sweepMu.Unlock()
+		}
+		atomic.StoreInt32(&gcArmed, 0)
+		// re-arm only while threads remain, the next thread created arms it otherwise
+		if live != 0 {
+			armGCSweep()
+		}
+	}()
+}
+
+// sweep removes the threads of exited goroutines, the next sweep happens after as many threads as alive are created.
+func sweep() {
+	sweepMu.Lock()
+	defer sweepMu.Unlock()
+	if atomic.LoadInt64(&created) < atomic.LoadInt64(&nextSweep) {
+		return
+	}
+	sweepLocked()
+}
+
+// sweepLocked removes the threads of exited goroutines and returns the number of threads left, sweepMu must be held.
+func sweepLocked() int64 {
+	var live int64
+	threads.Range(func(key, value any) bool {
+		th := value.(*thread)
+		if alive(th.gp, th.goid) {
+			live++
+		} else {
+			threads.Delete(key)
+		}
+		return true
+	})
+	next := live
+	if next < minSweep {
+		next = minSweep
+	}
+	atomic.StoreInt64(&created, 0)
+	atomic.StoreInt64(&nextSweep, next)
+	atomic.StoreInt64(&lastSweep, time.Now().UnixNano())
+	return live
+}
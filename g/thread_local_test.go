@@ -0,0 +1,118 @@
+package g
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countThreads() int {
+	n := 0
+	threads.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestThreadLocal(t *testing.T) {
+	local := NewThreadLocal[string]()
+	assert.Equal(t, "", local.Get())
+	local.Set("main")
+	assert.Equal(t, "main", local.Get())
+	runTest(t, func() {
+		assert.Equal(t, "", local.Get())
+		local.Set("child")
+		assert.Equal(t, "child", local.Get())
+	})
+	assert.Equal(t, "main", local.Get())
+	local.Remove()
+	assert.Equal(t, "", local.Get())
+}
+
+func TestThreadLocal_multiple(t *testing.T) {
+	a := NewThreadLocal[int]()
+	b := NewThreadLocal[int]()
+	a.Set(1)
+	b.Set(2)
+	assert.Equal(t, 1, a.Get())
+	assert.Equal(t, 2, b.Get())
+	a.Remove()
+	assert.Equal(t, 0, a.Get())
+	assert.Equal(t, 2, b.Get())
+	b.Remove()
+}
+
+func TestNewThreadLocalWithInitial(t *testing.T) {
+	var calls int32
+	local := NewThreadLocalWithInitial(func() *int {
+		atomic.AddInt32(&calls, 1)
+		v := 0
+		return &v
+	})
+	*local.Get()++
+	*local.Get()++
+	assert.Equal(t, 2, *local.Get())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	local.Remove()
+	assert.Equal(t, 0, *local.Get())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	local.Remove()
+}
+
+func TestThreadLocal_Remove_releases_thread(t *testing.T) {
+	local := NewThreadLocal[int]()
+	runTest(t, func() {
+		local.Set(1)
+		_, ok := threads.Load(Goid())
+		assert.True(t, ok)
+		local.Remove()
+		_, ok = threads.Load(Goid())
+		assert.False(t, ok)
+	})
+}
+
+func TestThreadLocal_sweep_exited_goroutines(t *testing.T) {
+	local := NewThreadLocal[int]()
+	before := countThreads()
+	var wg sync.WaitGroup
+	for i := 0; i < 3*minSweep; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			local.Set(i)
+		}(i)
+	}
+	wg.Wait()
+	// Goroutines may still be exiting after wg.Done, so force sweeps until all are removed.
+	assert.Eventually(t, func() bool {
+		atomic.StoreInt64(&nextSweep, 0)
+		sweep()
+		return countThreads() <= before
+	}, time.Second, time.Millisecond)
+	// Sweeps are also triggered automatically, so the number of threads stays bounded.
+	assert.Equal(t, int64(minSweep), atomic.LoadInt64(&nextSweep))
+}
+
+func TestThreadLocal_sweep_after_gc(t *testing.T) {
+	local := NewThreadLocal[[]byte]()
+	before := countThreads()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local.Set(make([]byte, 1024))
+		}()
+	}
+	wg.Wait()
+	// Far fewer goroutines than minSweep exited, their values are released after garbage collection.
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		return countThreads() <= before
+	}, 5*sweepInterval, 10*time.Millisecond)
+}
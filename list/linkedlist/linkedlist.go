@@ -38,3 +38,12 @@ func (l *LinkedList[T]) Pop() (T, bool) {
 	l.size--
 	return res, true
 }
+
+// Peek 返回链表头部的元素但不移除
+func (l *LinkedList[T]) Peek() (T, bool) {
+	var zero T
+	if l.head == nil {
+		return zero, false
+	}
+	return l.head.Value, true
+}
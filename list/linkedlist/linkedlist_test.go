@@ -18,3 +18,15 @@ func TestLinkedList_Len(t *testing.T) {
 	assert.False(t, exist)
 	assert.Equal(t, 0, linklist.Len())
 }
+
+func TestLinkedList_Peek(t *testing.T) {
+	var linklist = &LinkedList[int]{}
+	_, exist := linklist.Peek()
+	assert.False(t, exist)
+	linklist.Append(3)
+	linklist.Append(31)
+	peek, exist := linklist.Peek()
+	assert.True(t, exist)
+	assert.Equal(t, 3, peek)
+	assert.Equal(t, 2, linklist.Len())
+}
@@ -0,0 +1,316 @@
+package coroutine
+
+import (
+	"github.com/koleter/go-util/g"
+	"github.com/koleter/go-util/list/linkedlist"
+	"github.com/koleter/go-util/queue"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// ForkJoinPool 工作窃取协程池, 每个工作协程有自己的双端队列, 工作协程内 Fork 的子任务放入自己队列的队尾并从队尾取出执行,
+// 自己的队列为空时从其他工作协程队列的队首窃取任务. 适用于递归拆分的任务, 避免所有任务竞争同一个队列
+type ForkJoinPool struct {
+	workers     []*forkJoinWorker
+	current     sync.Map // 工作协程的 g.G() -> *forkJoinWorker
+	mu          sync.Mutex
+	cond        *sync.Cond
+	submissions linkedlist.LinkedList[func()] // 从工作协程以外提交的任务
+	stopped     bool
+	idle        int32 // 等待任务的工作协程数量
+	steals      uint64
+	wg          sync.WaitGroup
+}
+
+// forkJoinWorker 工作协程, 自己在队尾放入与取出任务, 其他工作协程从队首窃取任务
+type forkJoinWorker struct {
+	pool  *ForkJoinPool
+	mu    sync.Mutex
+	deque queue.Deque[func()]
+	seed  uint32 // 选择窃取对象的随机数种子
+}
+
+// NewForkJoinPool 创建一个新的实例, parallelism为工作协程数量, 小于等于0时使用 runtime.GOMAXPROCS(0)
+func NewForkJoinPool(parallelism int) *ForkJoinPool {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	p := &ForkJoinPool{
+		workers: make([]*forkJoinWorker, parallelism),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	for i := range p.workers {
+		p.workers[i] = &forkJoinWorker{
+			pool:  p,
+			deque: queue.NewGrowableDeque[func()](64),
+			seed:  uint32(i)*2654435761 + 1,
+		}
+	}
+	p.wg.Add(parallelism)
+	for _, w := range p.workers {
+		go w.run()
+	}
+	return p
+}
+
+// Parallelism 工作协程数量
+func (p *ForkJoinPool) Parallelism() int {
+	return len(p.workers)
+}
+
+// Steals 累计窃取任务的次数
+func (p *ForkJoinPool) Steals() uint64 {
+	return atomic.LoadUint64(&p.steals)
+}
+
+// Submit 提交一个任务, 协程池已停止时返回 ErrPoolStopped
+func (p *ForkJoinPool) Submit(task func()) error {
+	if !p.push(func() { runTask(task, defaultPanicHandler) }) {
+		return ErrPoolStopped
+	}
+	return nil
+}
+
+// Stop 停止协程池不再接收工作协程以外提交的任务, 已提交的任务与它们 Fork 的子任务会继续执行
+func (p *ForkJoinPool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	p.cond.Broadcast()
+}
+
+// Wait 等待所有任务执行完毕,调用该函数前必须调用 Stop
+func (p *ForkJoinPool) Wait() {
+	p.wg.Wait()
+}
+
+// push 在工作协程内时放入它的队列, 否则放入提交队列, 协程池已停止且不在工作协程内时返回false
+func (p *ForkJoinPool) push(f func()) bool {
+	if w := p.worker(); w != nil {
+		w.mu.Lock()
+		w.deque.PushBack(f)
+		w.mu.Unlock()
+		if atomic.LoadInt32(&p.idle) > 0 {
+			p.mu.Lock()
+			p.cond.Signal()
+			p.mu.Unlock()
+		}
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return false
+	}
+	p.submissions.Append(f)
+	if atomic.LoadInt32(&p.idle) > 0 {
+		p.cond.Signal()
+	}
+	return true
+}
+
+// worker 返回当前协程对应的工作协程, 不在工作协程内时返回nil
+func (p *ForkJoinPool) worker() *forkJoinWorker {
+	if w, ok := p.current.Load(g.G()); ok {
+		return w.(*forkJoinWorker)
+	}
+	return nil
+}
+
+// poll 取出一个提交队列中的任务
+func (p *ForkJoinPool) poll() func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, _ := p.submissions.Pop()
+	return f
+}
+
+// hasWork 是否还有任务等待执行, 调用前需要持有 p.mu
+func (p *ForkJoinPool) hasWork() bool {
+	if p.submissions.Len() > 0 {
+		return true
+	}
+	for _, w := range p.workers {
+		w.mu.Lock()
+		n := w.deque.Size()
+		w.mu.Unlock()
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// park 没有任务时等待, 返回false时工作协程应当退出
+func (p *ForkJoinPool) park() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 先增加idle再检查任务, 放入任务的协程看到idle大于0时会唤醒, 不会丢失唤醒
+	atomic.AddInt32(&p.idle, 1)
+	defer atomic.AddInt32(&p.idle, -1)
+	for {
+		if p.hasWork() {
+			return true
+		}
+		if p.stopped {
+			return false
+		}
+		p.cond.Wait()
+	}
+}
+
+// run 工作协程的主循环
+func (w *forkJoinWorker) run() {
+	p := w.pool
+	gp := g.G()
+	p.current.Store(gp, w)
+	defer func() {
+		p.current.Delete(gp)
+		p.wg.Done()
+	}()
+	for {
+		if f := w.pop(); f != nil {
+			f()
+		} else if f := p.poll(); f != nil {
+			f()
+		} else if f := w.steal(); f != nil {
+			f()
+		} else if !p.park() {
+			return
+		}
+	}
+}
+
+// pop 从自己队列的队尾取出任务
+func (w *forkJoinWorker) pop() func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f, _ := w.deque.PopBack()
+	return f
+}
+
+// steal 从随机选择的其他工作协程队列的队首窃取任务
+func (w *forkJoinWorker) steal() func() {
+	workers := w.pool.workers
+	n := len(workers)
+	if n == 1 {
+		return nil
+	}
+	w.seed ^= w.seed << 13
+	w.seed ^= w.seed >> 17
+	w.seed ^= w.seed << 5
+	start := int(w.seed % uint32(n))
+	for i := 0; i < n; i++ {
+		victim := workers[(start+i)%n]
+		if victim == w {
+			continue
+		}
+		victim.mu.Lock()
+		f, ok := victim.deque.PopFront()
+		victim.mu.Unlock()
+		if ok {
+			atomic.AddUint64(&w.pool.steals, 1)
+			return f
+		}
+	}
+	return nil
+}
+
+// helpUntil 等待done关闭期间执行自己队列中的任务或窃取其他工作协程的任务, 没有任务可以执行时返回
+func (w *forkJoinWorker) helpUntil(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if f := w.pop(); f != nil {
+			f()
+		} else if f := w.steal(); f != nil {
+			f()
+		} else {
+			return
+		}
+	}
+}
+
+// ForkJoinTask 通过 Fork 提交的子任务
+type ForkJoinTask[T any] struct {
+	pool  *ForkJoinPool
+	fn    func() T
+	state int32
+	done  chan struct{}
+	val   T
+	err   error
+}
+
+// Fork 提交一个子任务, 在工作协程内调用时放入当前工作协程的队列.
+// 协程池已停止且不在工作协程内调用时任务不会执行, Join 会以 ErrPoolStopped panic
+func Fork[T any](pool *ForkJoinPool, fn func() T) *ForkJoinTask[T] {
+	t := &ForkJoinTask[T]{pool: pool, fn: fn, done: make(chan struct{})}
+	if !pool.push(t.exec) {
+		t.err = ErrPoolStopped
+		close(t.done)
+	}
+	return t
+}
+
+// Invoke 提交一个任务并等待它执行完毕, 任务panic时返回 *PanicError, 通常作为递归拆分的入口在工作协程以外调用
+func Invoke[T any](pool *ForkJoinPool, fn func() T) (T, error) {
+	t := Fork(pool, fn)
+	t.await()
+	return t.val, t.err
+}
+
+// Join 等待子任务执行完毕并返回结果, 在工作协程内调用时等待期间会执行其他任务而不是阻塞工作协程.
+// 子任务panic时以 *PanicError panic, 使panic沿着拆分的层次向上传递
+func (t *ForkJoinTask[T]) Join() T {
+	t.await()
+	if t.err != nil {
+		panic(t.err)
+	}
+	return t.val
+}
+
+// IsDone 子任务是否已经执行完毕
+func (t *ForkJoinTask[T]) IsDone() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *ForkJoinTask[T]) await() {
+	if t.IsDone() {
+		return
+	}
+	if w := t.pool.worker(); w != nil {
+		// 子任务通常还在自己队列的队尾, 优先在当前工作协程中直接执行
+		w.helpUntil(t.done)
+	}
+	<-t.done
+}
+
+// exec 执行子任务, 已被其他工作协程执行时直接返回
+func (t *ForkJoinTask[T]) exec() {
+	if !atomic.CompareAndSwapInt32(&t.state, futurePending, futureRunning) {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(*PanicError); ok {
+				// 子任务Join时传递上来的panic, 保留最初的调用栈
+				t.err = err
+			} else {
+				t.err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}
+		atomic.StoreInt32(&t.state, futureDone)
+		close(t.done)
+	}()
+	t.val = t.fn()
+}
@@ -0,0 +1,79 @@
+package coroutine
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 递归拆分的任务: 工作窃取协程池中子任务进入各自工作协程的队列,
+// RoutinePool 中所有子任务都要经过同一个队列与条件变量
+const benchFibN = 27
+
+func BenchmarkForkJoinPool_Fib(b *testing.B) {
+	pool := NewForkJoinPool(runtime.GOMAXPROCS(0))
+	defer pool.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Invoke(pool, func() int { return forkFib(pool, benchFibN) }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// routineFib RoutinePool 的工作协程不能阻塞等待子任务, 子任务完成后通过回调汇总结果
+func routineFib(pool *RoutinePool, n int, cb func(int)) {
+	if n < 10 {
+		cb(fib(n))
+		return
+	}
+	var pending int32 = 2
+	var sum int64
+	child := func(v int) {
+		atomic.AddInt64(&sum, int64(v))
+		if atomic.AddInt32(&pending, -1) == 0 {
+			cb(int(atomic.LoadInt64(&sum)))
+		}
+	}
+	pool.Submit(func() { routineFib(pool, n-1, child) })
+	routineFib(pool, n-2, child)
+}
+
+func BenchmarkRoutinePool_Fib(b *testing.B) {
+	pool := NewRoutinePool(runtime.GOMAXPROCS(0))
+	defer pool.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		done := make(chan int, 1)
+		pool.Submit(func() {
+			routineFib(pool, benchFibN, func(v int) { done <- v })
+		})
+		<-done
+	}
+}
+
+// 从外部提交大量独立的小任务
+func BenchmarkForkJoinPool_Submit(b *testing.B) {
+	pool := NewForkJoinPool(runtime.GOMAXPROCS(0))
+	defer pool.Stop()
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	wg.Add(b.N)
+	for i := 0; i < b.N; i++ {
+		pool.Submit(wg.Done)
+	}
+	wg.Wait()
+}
+
+func BenchmarkRoutinePool_Submit(b *testing.B) {
+	pool := NewRoutinePool(runtime.GOMAXPROCS(0))
+	defer pool.Stop()
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	wg.Add(b.N)
+	for i := 0; i < b.N; i++ {
+		pool.Submit(wg.Done)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,116 @@
+package coroutine
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func forkFib(pool *ForkJoinPool, n int) int {
+	if n < 10 {
+		return fib(n)
+	}
+	f1 := Fork(pool, func() int { return forkFib(pool, n-1) })
+	f2 := forkFib(pool, n-2)
+	return f1.Join() + f2
+}
+
+func TestForkJoinPool_fib(t *testing.T) {
+	pool := NewForkJoinPool(4)
+	defer pool.Stop()
+	v, err := Invoke(pool, func() int { return forkFib(pool, 25) })
+	assert.NoError(t, err)
+	assert.Equal(t, fib(25), v)
+	assert.Equal(t, 4, pool.Parallelism())
+}
+
+func TestForkJoinPool_join_out_of_order(t *testing.T) {
+	pool := NewForkJoinPool(1)
+	defer pool.Stop()
+	// 只有一个工作协程时, 按Fork的顺序Join也不会死锁
+	v, err := Invoke(pool, func() int {
+		tasks := make([]*ForkJoinTask[int], 10)
+		for i := range tasks {
+			i := i
+			tasks[i] = Fork(pool, func() int { return i })
+		}
+		sum := 0
+		for _, task := range tasks {
+			sum += task.Join()
+		}
+		return sum
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 45, v)
+}
+
+func TestForkJoinPool_steal(t *testing.T) {
+	pool := NewForkJoinPool(4)
+	defer pool.Stop()
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	_, err := Invoke(pool, func() int {
+		tasks := make([]*ForkJoinTask[int], 4)
+		for i := range tasks {
+			tasks[i] = Fork(pool, func() int {
+				started <- struct{}{}
+				<-release
+				return 0
+			})
+		}
+		// 当前工作协程只能执行其中一个, 其余的被其他工作协程窃取后才能同时开始
+		go func() {
+			for i := 0; i < 4; i++ {
+				<-started
+			}
+			close(release)
+		}()
+		for _, task := range tasks {
+			task.Join()
+		}
+		return 0
+	})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, pool.Steals(), uint64(3))
+}
+
+func TestForkJoinPool_panic(t *testing.T) {
+	pool := NewForkJoinPool(2)
+	defer pool.Stop()
+	_, err := Invoke(pool, func() int {
+		task := Fork(pool, func() int { panic("boom") })
+		return task.Join()
+	})
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+}
+
+func TestForkJoinPool_Submit_and_Stop(t *testing.T) {
+	pool := NewForkJoinPool(2)
+	var ran int32
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, pool.Submit(func() {
+			// 停止后工作协程内 Fork 的子任务仍会执行
+			Fork(pool, func() int {
+				atomic.AddInt32(&ran, 1)
+				return 0
+			})
+		}))
+	}
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, int32(100), atomic.LoadInt32(&ran))
+
+	assert.Equal(t, ErrPoolStopped, pool.Submit(func() {}))
+	_, err := Invoke(pool, func() int { return 0 })
+	assert.Equal(t, ErrPoolStopped, err)
+}
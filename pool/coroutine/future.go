@@ -0,0 +1,167 @@
+package coroutine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+var (
+	// ErrCancelled 任务在开始执行前被取消
+	ErrCancelled = errors.New("future is cancelled")
+	// ErrPoolStopped 协程池已停止, 任务没有被接收
+	ErrPoolStopped = errors.New("routine pool is stopped")
+)
+
+// PanicError 任务执行时发生panic, 携带panic的值与发生时的调用栈
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v\nstack: %s", e.Value, e.Stack)
+}
+
+const (
+	futurePending int32 = iota
+	futureRunning
+	futureDone
+	futureCancelled
+)
+
+// Future 异步任务的结果
+type Future[T any] struct {
+	state int32
+	done  chan struct{}
+	val   T
+	err   error
+}
+
+// SubmitFunc 提交一个有返回值的任务, 任务panic时通过 Get 返回 *PanicError,
+// 任务没有被协程池接收或被拒绝策略丢弃时通过 Get 返回对应的错误
+func SubmitFunc[T any](pool *RoutinePool, fn func() (T, error)) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+	if err := pool.submit(func() { f.run(fn) }, f.reject); err != nil {
+		f.reject(err)
+	}
+	return f
+}
+
+// SubmitFuncContext 提交一个可以感知取消的有返回值的任务, 任务接收协程池的上下文
+func SubmitFuncContext[T any](pool *RoutinePool, fn func(ctx context.Context) (T, error)) *Future[T] {
+	return SubmitFunc(pool, func() (T, error) {
+		return fn(pool.ctx)
+	})
+}
+
+// reject 任务没有被协程池接收或被丢弃时以err结束
+func (f *Future[T]) reject(err error) {
+	if atomic.CompareAndSwapInt32(&f.state, futurePending, futureDone) {
+		f.err = err
+		close(f.done)
+	}
+}
+
+// run 执行任务, 任务已被取消时直接返回
+func (f *Future[T]) run(fn func() (T, error)) {
+	if !atomic.CompareAndSwapInt32(&f.state, futurePending, futureRunning) {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			f.err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		atomic.StoreInt32(&f.state, futureDone)
+		close(f.done)
+	}()
+	f.val, f.err = fn()
+}
+
+// Get 等待任务完成并返回结果
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.val, f.err
+}
+
+// GetContext 等待任务完成并返回结果, ctx结束时返回ctx.Err(), 任务不受影响
+func (f *Future[T]) GetContext(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 任务完成或被取消时关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消还未开始执行的任务, 任务已经开始或已经结束时返回false
+func (f *Future[T]) Cancel() bool {
+	if !atomic.CompareAndSwapInt32(&f.state, futurePending, futureCancelled) {
+		return false
+	}
+	f.err = ErrCancelled
+	close(f.done)
+	return true
+}
+
+// IsCancelled 任务是否被取消
+func (f *Future[T]) IsCancelled() bool {
+	return atomic.LoadInt32(&f.state) == futureCancelled
+}
+
+// InvokeAll 提交所有任务并等待全部完成, 按提交顺序返回结果
+func InvokeAll[T any](pool *RoutinePool, fns ...func() (T, error)) []*Future[T] {
+	futures := make([]*Future[T], len(fns))
+	for i, fn := range fns {
+		futures[i] = SubmitFunc(pool, fn)
+	}
+	for _, f := range futures {
+		<-f.done
+	}
+	return futures
+}
+
+// InvokeAny 提交所有任务, 返回第一个成功完成的结果并取消其余还未开始的任务,
+// 全部失败时返回最后一个错误, ctx结束时返回ctx.Err()
+func InvokeAny[T any](ctx context.Context, pool *RoutinePool, fns ...func() (T, error)) (T, error) {
+	var zero T
+	if len(fns) == 0 {
+		return zero, errors.New("no task to invoke")
+	}
+	futures := make([]*Future[T], len(fns))
+	results := make(chan *Future[T], len(fns))
+	for i, fn := range fns {
+		f := SubmitFunc(pool, fn)
+		futures[i] = f
+		go func() {
+			<-f.done
+			results <- f
+		}()
+	}
+	defer func() {
+		for _, f := range futures {
+			f.Cancel()
+		}
+	}()
+	var err error
+	for range fns {
+		select {
+		case f := <-results:
+			if f.err == nil {
+				return f.val, nil
+			}
+			err = f.err
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+	return zero, err
+}
@@ -0,0 +1,135 @@
+package coroutine
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSubmitFunc_Get(t *testing.T) {
+	pool := NewRoutinePool(2)
+	defer pool.Stop()
+	f := SubmitFunc(pool, func() (int, error) {
+		return 42, nil
+	})
+	v, err := f.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+
+	myErr := errors.New("failed")
+	f2 := SubmitFunc(pool, func() (string, error) {
+		return "", myErr
+	})
+	_, err = f2.Get()
+	assert.ErrorIs(t, err, myErr)
+}
+
+func TestSubmitFunc_panic(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	f := SubmitFunc(pool, func() (int, error) {
+		panic("boom")
+	})
+	_, err := f.Get()
+	var panicErr *PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "TestSubmitFunc_panic")
+	}
+}
+
+func TestFuture_Cancel_before_start(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	block := make(chan struct{})
+	first := SubmitFunc(pool, func() (int, error) {
+		<-block
+		return 1, nil
+	})
+	second := SubmitFunc(pool, func() (int, error) {
+		t.Error("cancelled task should not be executed")
+		return 2, nil
+	})
+	assert.True(t, second.Cancel())
+	assert.True(t, second.IsCancelled())
+	_, err := second.Get()
+	assert.ErrorIs(t, err, ErrCancelled)
+
+	close(block)
+	v, err := first.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.False(t, first.Cancel())
+}
+
+func TestFuture_GetContext(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	f := SubmitFunc(pool, func() (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		return 1, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := f.GetContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case <-f.Done():
+		t.Error("future should not be done yet")
+	default:
+	}
+	v, err := f.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestSubmitFunc_pool_stopped(t *testing.T) {
+	pool := NewRoutinePool(1)
+	pool.Stop()
+	_, err := SubmitFunc(pool, func() (int, error) {
+		return 1, nil
+	}).Get()
+	assert.ErrorIs(t, err, ErrPoolStopped)
+}
+
+func TestInvokeAll(t *testing.T) {
+	pool := NewRoutinePool(3)
+	defer pool.Stop()
+	var fns []func() (int, error)
+	for i := 0; i < 10; i++ {
+		i := i
+		fns = append(fns, func() (int, error) {
+			return i * i, nil
+		})
+	}
+	futures := InvokeAll(pool, fns...)
+	for i, f := range futures {
+		v, err := f.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, i*i, v)
+	}
+}
+
+func TestInvokeAny(t *testing.T) {
+	pool := NewRoutinePool(2)
+	defer pool.Stop()
+	v, err := InvokeAny(context.Background(), pool,
+		func() (string, error) {
+			return "", errors.New("failed")
+		},
+		func() (string, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "ok", nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", v)
+
+	myErr := errors.New("all failed")
+	_, err = InvokeAny(context.Background(), pool, func() (int, error) {
+		return 0, myErr
+	})
+	assert.ErrorIs(t, err, myErr)
+}
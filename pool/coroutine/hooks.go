@@ -0,0 +1,89 @@
+package coroutine
+
+import (
+	"log"
+	"time"
+)
+
+// PanicHandler 任务panic时的处理函数, r为panic的值, stack为发生panic时的调用栈
+type PanicHandler func(r any, stack []byte)
+
+// TaskWrapper 包装任务的函数, 返回的函数中调用next执行任务, 可以在任务前后执行自定义逻辑
+type TaskWrapper func(next func()) func()
+
+func defaultPanicHandler(r any, stack []byte) {
+	log.Printf("Task panicked: %v\nstack: %s", r, stack)
+}
+
+// WithPanicHandler 设置任务panic时的处理函数, 默认打印日志; 通过 SubmitFunc 提交的任务的panic由 Future 返回, 不会调用该函数
+func WithPanicHandler(handler PanicHandler) Option {
+	return func(o *options) {
+		o.panicHandler = handler
+	}
+}
+
+// WithBeforeTask 添加任务执行前调用的函数, 多次设置时按添加顺序调用
+func WithBeforeTask(f func()) Option {
+	return func(o *options) {
+		o.beforeTask = append(o.beforeTask, f)
+	}
+}
+
+// WithAfterTask 添加任务执行后调用的函数, elapsed为任务的执行耗时, 任务panic时同样会调用, 多次设置时按添加顺序调用
+func WithAfterTask(f func(elapsed time.Duration)) Option {
+	return func(o *options) {
+		o.afterTask = append(o.afterTask, f)
+	}
+}
+
+// WithTaskWrapper 添加任务的包装函数, 多次设置时先添加的包装在最外层, 执行前后的函数在所有包装之内调用
+func WithTaskWrapper(wrapper TaskWrapper) Option {
+	return func(o *options) {
+		o.wrappers = append(o.wrappers, wrapper)
+	}
+}
+
+// WithWorkerStart 添加工作协程启动时在该协程中调用的函数
+func WithWorkerStart(f func()) Option {
+	return func(o *options) {
+		o.workerStart = append(o.workerStart, f)
+	}
+}
+
+// WithWorkerStop 添加工作协程退出时在该协程中调用的函数
+func WithWorkerStop(f func()) Option {
+	return func(o *options) {
+		o.workerStop = append(o.workerStop, f)
+	}
+}
+
+// runTask 按配置的包装与执行前后的函数执行任务, panic时交给panic处理函数
+func (p *RoutinePool) runTask(task func()) {
+	runTask(p.wrapTask(task), p.opts.panicHandler)
+}
+
+// wrapTask 按配置包装任务
+func (p *RoutinePool) wrapTask(task func()) func() {
+	run := task
+	if len(p.opts.beforeTask) > 0 || len(p.opts.afterTask) > 0 {
+		run = func() {
+			for _, f := range p.opts.beforeTask {
+				f()
+			}
+			if len(p.opts.afterTask) > 0 {
+				start := time.Now()
+				defer func() {
+					elapsed := time.Since(start)
+					for _, f := range p.opts.afterTask {
+						f(elapsed)
+					}
+				}()
+			}
+			task()
+		}
+	}
+	for i := len(p.opts.wrappers) - 1; i >= 0; i-- {
+		run = p.opts.wrappers[i](run)
+	}
+	return run
+}
@@ -0,0 +1,205 @@
+package coroutine
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koleter/go-util/g"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPanicHandler(t *testing.T) {
+	var mu sync.Mutex
+	var values []any
+	var stack string
+	pool := NewRoutinePool(1, WithPanicHandler(func(r any, s []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, r)
+		stack = string(s)
+	}))
+	pool.Submit(func() { panic("boom") })
+	pool.Submit(func() {})
+	pool.Stop()
+	pool.Wait()
+
+	assert.Equal(t, []any{"boom"}, values)
+	assert.True(t, strings.Contains(stack, "TestWithPanicHandler"), stack)
+}
+
+func TestWithPanicHandler_not_called_for_future(t *testing.T) {
+	var called int32
+	pool := NewRoutinePool(1, WithPanicHandler(func(any, []byte) {
+		atomic.AddInt32(&called, 1)
+	}))
+	_, err := SubmitFunc(pool, func() (int, error) { panic("boom") }).Get()
+	assert.Error(t, err)
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&called))
+}
+
+func TestTaskHooks_order(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+	wrapper := func(name string) TaskWrapper {
+		return func(next func()) func() {
+			return func() {
+				record(name + " begin")
+				defer record(name + " end")
+				next()
+			}
+		}
+	}
+	var elapsed time.Duration
+	pool := NewRoutinePool(1,
+		WithTaskWrapper(wrapper("outer")),
+		WithTaskWrapper(wrapper("inner")),
+		WithBeforeTask(func() { record("before") }),
+		WithAfterTask(func(d time.Duration) {
+			elapsed = d
+			record("after")
+		}),
+		WithPanicHandler(func(any, []byte) { record("panic") }),
+	)
+	pool.Submit(func() {
+		time.Sleep(5 * time.Millisecond)
+		record("task")
+		panic("boom")
+	})
+	pool.Stop()
+	pool.Wait()
+
+	assert.Equal(t, []string{
+		"outer begin", "inner begin", "before", "task", "after", "inner end", "outer end", "panic",
+	}, events)
+	assert.GreaterOrEqual(t, elapsed, 5*time.Millisecond)
+}
+
+func TestTaskHooks_caller_runs(t *testing.T) {
+	var before int32
+	pool, block := blockPool(t,
+		WithQueueCapacity(1),
+		WithRejectPolicy(CallerRuns),
+		WithBeforeTask(func() { atomic.AddInt32(&before, 1) }),
+	)
+	pool.Submit(func() {})
+	// 队列已满, 在当前协程中执行的任务同样调用执行前的函数
+	pool.Submit(func() {})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&before))
+	close(block)
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&before))
+}
+
+func TestWorkerHooks(t *testing.T) {
+	var started, stopped int32
+	pool := NewRoutinePool(3,
+		WithWorkerStart(func() { atomic.AddInt32(&started, 1) }),
+		WithWorkerStop(func() { atomic.AddInt32(&stopped, 1) }),
+	)
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&started) == 3
+	})
+	assert.Equal(t, int32(0), atomic.LoadInt32(&stopped))
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&stopped))
+}
+
+func TestWithInheritableThreadLocals(t *testing.T) {
+	requestID := g.NewInheritableThreadLocal[string]()
+	pool := NewRoutinePool(1, WithInheritableThreadLocals())
+	defer pool.Stop()
+
+	requestID.Set("req-1")
+	defer requestID.Remove()
+	v, err := SubmitFunc(pool, func() (string, error) {
+		return requestID.Get(), nil
+	}).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", v)
+
+	// 值只在任务执行期间有效, 不会残留在工作协程中
+	requestID.Remove()
+	v, _ = SubmitFunc(pool, func() (string, error) {
+		return requestID.Get(), nil
+	}).Get()
+	assert.Equal(t, "", v)
+}
+
+func TestWithInheritableThreadLocals_SubmitKeyed(t *testing.T) {
+	requestID := g.NewInheritableThreadLocal[string]()
+	pool := NewRoutinePool(1, WithInheritableThreadLocals())
+	defer pool.Stop()
+	e := NewKeyedExecutor[string](pool, 0)
+
+	// 阻塞第一个任务, 让后面的任务追加到同一个key的队列中
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	submit := func(v string) {
+		requestID.Set(v)
+		defer requestID.Remove()
+		assert.NoError(t, e.SubmitKeyed("k", func() {
+			if v == "A" {
+				<-block
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, requestID.Get())
+		}))
+	}
+	submit("A")
+	submit("B")
+	close(block)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	})
+	assert.Equal(t, []string{"A", "B"}, got)
+}
+
+func TestWithInheritableThreadLocals_Schedule(t *testing.T) {
+	requestID := g.NewInheritableThreadLocal[string]()
+	pool := NewRoutinePool(1, WithInheritableThreadLocals())
+	defer pool.Stop()
+	e := NewScheduledExecutor(pool, nil)
+	defer e.Stop()
+
+	requestID.Set("req-1")
+	defer requestID.Remove()
+	result := make(chan string, 1)
+	_, err := e.Schedule(time.Millisecond, func() {
+		result <- requestID.Get()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", <-result)
+}
+
+func TestWithInheritableThreadLocals_Pipeline(t *testing.T) {
+	requestID := g.NewInheritableThreadLocal[string]()
+	pool := NewRoutinePool(2, WithInheritableThreadLocals())
+	defer pool.Stop()
+
+	requestID.Set("req-1")
+	defer requestID.Remove()
+	p := NewPipeline(context.Background(), pool)
+	s := Map(FromSlice(p, []int{1, 2, 3}), func(ctx context.Context, in int) (string, error) {
+		return requestID.Get(), nil
+	})
+	got, err := Collect(s)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"req-1", "req-1", "req-1"}, got)
+}
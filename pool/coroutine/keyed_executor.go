@@ -0,0 +1,165 @@
+package coroutine
+
+import (
+	"github.com/koleter/go-util/list/linkedlist"
+	"sync"
+)
+
+// KeyedExecutor 按key串行执行任务的执行器, 同一个key的任务按提交顺序依次执行且不会同时执行, 不同key的任务在 RoutinePool 中并发执行
+type KeyedExecutor[K comparable] struct {
+	pool     *RoutinePool
+	capacity int
+	mu       sync.Mutex
+	queues   map[K]*keyedQueue
+	queued   int // 已接收但还未开始执行的任务数量
+}
+
+// keyedQueue 同一个key的任务队列, 存在即表示该key有任务已交给协程池, 队列为空时移除
+type keyedQueue struct {
+	tasks linkedlist.LinkedList[func()]
+	// dropped 执行队列的协程池任务被 ShutdownNow 移出, 队列已从执行器中移除并且不再计入等待执行的任务数量
+	dropped bool
+}
+
+// NewKeyedExecutor 创建一个新的实例, 任务在pool中执行, capacity为所有key等待执行的任务总数上限, 小于等于0时不限制
+func NewKeyedExecutor[K comparable](pool *RoutinePool, capacity int) *KeyedExecutor[K] {
+	return &KeyedExecutor[K]{
+		pool:     pool,
+		capacity: capacity,
+		queues:   map[K]*keyedQueue{},
+	}
+}
+
+// SubmitKeyed 提交key的任务, 等待执行的任务总数达到上限时返回 ErrQueueFull, 协程池已停止时返回 ErrPoolStopped, 协程池拒绝时返回对应的错误.
+// 任务交给协程池后被拒绝策略丢弃时, 该key所有等待执行的任务都会被丢弃
+func (e *KeyedExecutor[K]) SubmitKeyed(key K, task func()) error {
+	if e.pool.IsShutdown() {
+		return ErrPoolStopped
+	}
+	// 同一个key的任务可能由其他提交者的协程池任务执行, 每个任务都需要捕获自己的提交者的值
+	task = e.pool.capture(task)
+	e.mu.Lock()
+	if e.capacity > 0 && e.queued >= e.capacity {
+		e.mu.Unlock()
+		return ErrQueueFull
+	}
+	e.queued++
+	if q, ok := e.queues[key]; ok {
+		q.tasks.Append(task)
+		e.mu.Unlock()
+		return nil
+	}
+	q := &keyedQueue{}
+	q.tasks.Append(task)
+	e.queues[key] = q
+	e.mu.Unlock()
+
+	if err := e.pool.submitTask(e.newTask(key, q)); err != nil {
+		e.retract(key, q)
+		return err
+	}
+	return nil
+}
+
+// retract 协程池没有接收key的队列时移除当前提交者的任务, 即队首的任务;
+// 其他协程在此期间追加的任务已经被接收, 重新交给协程池, 协程池无法接收时在当前协程中执行
+func (e *KeyedExecutor[K]) retract(key K, q *keyedQueue) {
+	e.mu.Lock()
+	q.tasks.Pop()
+	e.queued--
+	if q.tasks.Len() == 0 {
+		if e.queues[key] == q {
+			delete(e.queues, key)
+		}
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+	if !e.pool.offer(e.newTask(key, q)) {
+		e.run(key, q)
+	}
+}
+
+// Len 所有key等待执行的任务总数
+func (e *KeyedExecutor[K]) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.queued
+}
+
+// Keys 有任务在等待或执行的key的数量
+func (e *KeyedExecutor[K]) Keys() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.queues)
+}
+
+// run 执行key队首的任务, 之后把下一个任务重新提交给协程池, 使其他key的任务也有机会执行
+func (e *KeyedExecutor[K]) run(key K, q *keyedQueue) {
+	for {
+		e.mu.Lock()
+		task, ok := q.tasks.Pop()
+		if !ok {
+			// 队列已被拒绝策略丢弃
+			e.mu.Unlock()
+			return
+		}
+		if !q.dropped {
+			e.queued--
+		}
+		e.mu.Unlock()
+
+		runTask(task, e.pool.opts.panicHandler)
+
+		e.mu.Lock()
+		if q.tasks.Len() == 0 {
+			if e.queues[key] == q {
+				delete(e.queues, key)
+			}
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+		if e.pool.offer(e.newTask(key, q)) {
+			return
+		}
+		// 协程池无法接收时在当前工作协程中继续执行, 已接收的任务不会丢失
+	}
+}
+
+// newTask 执行key队首任务的协程池任务
+func (e *KeyedExecutor[K]) newTask(key K, q *keyedQueue) *task {
+	return &task{
+		captured: true,
+		run:      func() { e.run(key, q) },
+		reject:   func(error) { e.discard(key, q) },
+		dropped:  func() { e.drop(key, q) },
+	}
+}
+
+// drop 执行key队列的协程池任务被 ShutdownNow 移出时移除队列, 队列中的任务只会在调用 ShutdownNow 返回的函数时执行
+func (e *KeyedExecutor[K]) drop(key K, q *keyedQueue) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if q.dropped {
+		return
+	}
+	q.dropped = true
+	e.queued -= q.tasks.Len()
+	if e.queues[key] == q {
+		delete(e.queues, key)
+	}
+}
+
+// discard 丢弃key所有等待执行的任务
+func (e *KeyedExecutor[K]) discard(key K, q *keyedQueue) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !q.dropped {
+		e.queued -= q.tasks.Len()
+	}
+	q.tasks = linkedlist.LinkedList[func()]{}
+	if e.queues[key] == q {
+		delete(e.queues, key)
+	}
+}
@@ -0,0 +1,182 @@
+package coroutine
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedExecutor_ordered_per_key(t *testing.T) {
+	pool := NewRoutinePool(8)
+	executor := NewKeyedExecutor[int](pool, 0)
+
+	const keys, perKey = 10, 200
+	var mu sync.Mutex
+	results := make(map[int][]int)
+	running := make([]int32, keys)
+	var overlapped int32
+	for i := 0; i < perKey; i++ {
+		for key := 0; key < keys; key++ {
+			key, i := key, i
+			assert.NoError(t, executor.SubmitKeyed(key, func() {
+				if atomic.AddInt32(&running[key], 1) != 1 {
+					atomic.StoreInt32(&overlapped, 1)
+				}
+				mu.Lock()
+				results[key] = append(results[key], i)
+				mu.Unlock()
+				atomic.AddInt32(&running[key], -1)
+			}))
+		}
+	}
+	waitFor(t, func() bool {
+		return executor.Keys() == 0
+	})
+	pool.Stop()
+	pool.Wait()
+
+	assert.Equal(t, int32(0), overlapped)
+	assert.Equal(t, 0, executor.Len())
+	for key := 0; key < keys; key++ {
+		assert.Len(t, results[key], perKey)
+		for i, v := range results[key] {
+			if v != i {
+				t.Fatalf("key %d executed out of order: %v", key, results[key])
+			}
+		}
+	}
+}
+
+func TestKeyedExecutor_parallel_across_keys(t *testing.T) {
+	pool := NewRoutinePool(2)
+	defer pool.Stop()
+	executor := NewKeyedExecutor[string](pool, 0)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	barrier := func() {
+		wg.Done()
+		wg.Wait()
+	}
+	done := make(chan struct{}, 2)
+	executor.SubmitKeyed("a", func() { barrier(); done <- struct{}{} })
+	executor.SubmitKeyed("b", func() { barrier(); done <- struct{}{} })
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Tasks of different keys should run concurrently")
+		}
+	}
+}
+
+func TestKeyedExecutor_capacity(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	executor := NewKeyedExecutor[int](pool, 2)
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	assert.NoError(t, executor.SubmitKeyed(1, func() {
+		close(started)
+		<-block
+	}))
+	<-started
+	assert.NoError(t, executor.SubmitKeyed(1, func() {}))
+	assert.NoError(t, executor.SubmitKeyed(2, func() {}))
+	assert.Equal(t, ErrQueueFull, executor.SubmitKeyed(3, func() {}))
+	assert.Equal(t, 2, executor.Len())
+	assert.Equal(t, 2, executor.Keys())
+
+	close(block)
+	waitFor(t, func() bool {
+		return executor.Keys() == 0
+	})
+	assert.Equal(t, 0, executor.Len())
+}
+
+func TestKeyedExecutor_panic_does_not_stop_key(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	executor := NewKeyedExecutor[int](pool, 0)
+
+	done := make(chan struct{})
+	executor.SubmitKeyed(1, func() { panic("boom") })
+	executor.SubmitKeyed(1, func() { close(done) })
+	<-done
+}
+
+func TestKeyedExecutor_pool_stopped(t *testing.T) {
+	pool := NewRoutinePool(1)
+	executor := NewKeyedExecutor[int](pool, 0)
+	pool.Stop()
+	assert.Equal(t, ErrPoolStopped, executor.SubmitKeyed(1, func() {}))
+	assert.Equal(t, 0, executor.Len())
+	assert.Equal(t, 0, executor.Keys())
+}
+
+func TestKeyedExecutor_continues_when_pool_full(t *testing.T) {
+	pool := NewRoutinePool(1, WithQueueCapacity(1), WithRejectPolicy(Block))
+	executor := NewKeyedExecutor[int](pool, 0)
+
+	var ran int32
+	block := make(chan struct{})
+	executor.SubmitKeyed(1, func() { <-block })
+	for i := 0; i < 3; i++ {
+		executor.SubmitKeyed(1, func() { atomic.AddInt32(&ran, 1) })
+	}
+	// 占满协程池的队列, key 1的后续任务只能在当前工作协程中继续执行
+	pool.Submit(func() {})
+	close(block)
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&ran) == 3
+	})
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestKeyedExecutor_retract_keeps_accepted_tasks(t *testing.T) {
+	pool := NewRoutinePool(1)
+	pool.Stop()
+	executor := NewKeyedExecutor[int](pool, 0)
+
+	// 模拟提交者发布队列后、协程池拒绝前, 其他协程追加了任务
+	var own, other int32
+	q := &keyedQueue{}
+	q.tasks.Append(func() { atomic.AddInt32(&own, 1) })
+	q.tasks.Append(func() { atomic.AddInt32(&other, 1) })
+	executor.queues[1] = q
+	executor.queued = 2
+
+	executor.retract(1, q)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&own))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&other))
+	assert.Equal(t, 0, executor.Len())
+	assert.Equal(t, 0, executor.Keys())
+}
+
+func TestKeyedExecutor_ShutdownNow(t *testing.T) {
+	pool, block := blockPool(t)
+	defer close(block)
+	executor := NewKeyedExecutor[int](pool, 0)
+
+	var ran int32
+	assert.NoError(t, executor.SubmitKeyed(1, func() { atomic.AddInt32(&ran, 1) }))
+	assert.NoError(t, executor.SubmitKeyed(1, func() { atomic.AddInt32(&ran, 1) }))
+	assert.Equal(t, 2, executor.Len())
+
+	tasks := pool.ShutdownNow()
+	assert.Equal(t, 1, len(tasks))
+	// 被移出的队列不再留在执行器中
+	assert.Equal(t, 0, executor.Len())
+	assert.Equal(t, 0, executor.Keys())
+	assert.Equal(t, ErrPoolStopped, executor.SubmitKeyed(1, func() {}))
+
+	// 执行返回的函数时key的任务仍会执行
+	tasks[0]()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&ran))
+	assert.Equal(t, 0, executor.Len())
+}
@@ -0,0 +1,334 @@
+package coroutine
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/koleter/go-util/g"
+)
+
+// Pipeline 多级流水线, 各级之间通过有界缓冲连接, 处理函数在 RoutinePool 中执行.
+// 任意一级返回错误或panic时取消流水线的上下文, 所有级尽快停止, 最终由 Collect 或 ForEach 返回第一个错误
+type Pipeline struct {
+	pool   *RoutinePool
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	err    error
+}
+
+// Stream 流水线中某一级的输出
+type Stream[T any] struct {
+	p  *Pipeline
+	ch <-chan T
+}
+
+// StageOption 流水线中一级的配置项
+type StageOption func(*stageOptions)
+
+type stageOptions struct {
+	workers int
+	buffer  int
+	ordered bool
+}
+
+// WithStageWorkers 设置这一级同时处理的元素数量, 默认为1
+func WithStageWorkers(workers int) StageOption {
+	return func(o *stageOptions) {
+		o.workers = workers
+	}
+}
+
+// WithStageBuffer 设置这一级输出缓冲的大小, 默认为0, 缓冲已满时这一级等待下一级读取
+func WithStageBuffer(buffer int) StageOption {
+	return func(o *stageOptions) {
+		o.buffer = buffer
+	}
+}
+
+// WithOrdered 设置这一级按输入的顺序输出, 默认按处理完成的顺序输出
+func WithOrdered() StageOption {
+	return func(o *stageOptions) {
+		o.ordered = true
+	}
+}
+
+func newStageOptions(opts []StageOption) stageOptions {
+	o := stageOptions{workers: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers <= 0 {
+		panic(fmt.Sprintf("workers is less than or equal to 0, workers: %d", o.workers))
+	}
+	if o.buffer < 0 {
+		o.buffer = 0
+	}
+	return o
+}
+
+// NewPipeline 创建一个新的流水线, 处理函数在pool中执行, ctx结束时流水线停止
+func NewPipeline(ctx context.Context, pool *RoutinePool) *Pipeline {
+	p := &Pipeline{pool: pool, parent: ctx}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	return p
+}
+
+// Context 流水线的上下文, 流水线出错或结束时被取消
+func (p *Pipeline) Context() context.Context {
+	return p.ctx
+}
+
+// Err 流水线的第一个错误, 没有错误时如果外部的ctx已结束则返回ctx.Err()
+func (p *Pipeline) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	return p.parent.Err()
+}
+
+// fail 记录第一个错误并取消流水线
+func (p *Pipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// FromSlice 以items作为流水线的输入
+func FromSlice[T any](p *Pipeline, items []T) *Stream[T] {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, item := range items {
+			select {
+			case out <- item:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+	return &Stream[T]{p: p, ch: out}
+}
+
+// FromChan 以ch作为流水线的输入, ch关闭时输入结束
+func FromChan[T any](p *Pipeline, ch <-chan T) *Stream[T] {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case item, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- item:
+				case <-p.ctx.Done():
+					return
+				}
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+	return &Stream[T]{p: p, ch: out}
+}
+
+// stageResult 处理结果, seq为输入的序号
+type stageResult[T any] struct {
+	seq uint64
+	val T
+	ok  bool // 处理失败时为false
+}
+
+// Map 添加一级处理, 每个输入元素交给协程池执行fn, 同时处理的元素不超过配置的数量, fn返回错误时流水线停止
+func Map[In, Out any](s *Stream[In], fn func(ctx context.Context, in In) (Out, error), opts ...StageOption) *Stream[Out] {
+	p := s.p
+	o := newStageOptions(opts)
+	out := make(chan Out, o.buffer)
+	// 每个元素占用一个名额直到被输出, 结果通道的容量与名额相同, 协程池中的任务发送结果时不会阻塞
+	slots := make(chan struct{}, o.workers)
+	results := make(chan stageResult[Out], o.workers)
+	// 处理元素的任务由内部协程提交, 在这里捕获调用者的 g.InheritableThreadLocal 的值
+	inherit := p.pool.opts.inherit
+	var snapshot g.Snapshot
+	if inherit {
+		snapshot = g.Capture()
+	}
+
+	var wg sync.WaitGroup
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(results)
+		}()
+		var seq uint64
+		for in := range s.ch {
+			select {
+			case slots <- struct{}{}:
+			case <-p.ctx.Done():
+				return
+			}
+			in, r := in, stageResult[Out]{seq: seq}
+			seq++
+			wg.Add(1)
+			run := func() {
+				defer wg.Done()
+				if p.ctx.Err() == nil {
+					// 在协程池中排队期间流水线可能已经停止
+					r.val, r.ok = callStage(p, func() (Out, error) { return fn(p.ctx, in) })
+				}
+				results <- r
+			}
+			reject := func(err error) {
+				defer wg.Done()
+				p.fail(err)
+			}
+			task := run
+			if inherit {
+				task = func() { snapshot.Run(run) }
+			}
+			if err := p.pool.submitCaptured(task, reject); err != nil {
+				reject(err)
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		emit := func(v Out) bool {
+			select {
+			case out <- v:
+				<-slots
+				return true
+			case <-p.ctx.Done():
+				return false
+			}
+		}
+		pending := map[uint64]Out{}
+		var next uint64
+		for r := range results {
+			if !r.ok {
+				return
+			}
+			if !o.ordered {
+				if !emit(r.val) {
+					return
+				}
+				continue
+			}
+			pending[r.seq] = r.val
+			for v, ok := pending[next]; ok; v, ok = pending[next] {
+				delete(pending, next)
+				next++
+				if !emit(v) {
+					return
+				}
+			}
+		}
+	}()
+	return &Stream[Out]{p: p, ch: out}
+}
+
+// callStage 执行处理函数, 返回错误或panic时停止流水线并返回false
+func callStage[T any](p *Pipeline, fn func() (T, error)) (v T, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail(&PanicError{Value: r, Stack: debug.Stack()})
+			ok = false
+		}
+	}()
+	v, err := fn()
+	if err != nil {
+		p.fail(err)
+		return v, false
+	}
+	return v, true
+}
+
+// Batch 添加一级分组, 每凑满size个元素或距离这一组的第一个元素超过interval时输出一组, interval小于等于0时只按数量分组
+func Batch[T any](s *Stream[T], size int, interval time.Duration, opts ...StageOption) *Stream[[]T] {
+	if size <= 0 {
+		panic(fmt.Sprintf("size is less than or equal to 0, size: %d", size))
+	}
+	p := s.p
+	o := newStageOptions(opts)
+	out := make(chan []T, o.buffer)
+	go func() {
+		defer close(out)
+		var batch []T
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if len(batch) == 0 {
+				return true
+			}
+			select {
+			case out <- batch:
+				batch = nil
+				return true
+			case <-p.ctx.Done():
+				return false
+			}
+		}
+		for {
+			select {
+			case item, ok := <-s.ch:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, item)
+				if len(batch) >= size {
+					if !flush() {
+						return
+					}
+				} else if len(batch) == 1 && interval > 0 {
+					timer = time.NewTimer(interval)
+					timeout = timer.C
+				}
+			case <-timeout:
+				timer, timeout = nil, nil
+				if !flush() {
+					return
+				}
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+	return &Stream[[]T]{p: p, ch: out}
+}
+
+// ForEach 依次对流水线的每个输出调用fn, 返回流水线的第一个错误
+func ForEach[T any](s *Stream[T], fn func(T)) error {
+	p := s.p
+	defer p.cancel()
+	for v := range s.ch {
+		fn(v)
+	}
+	return p.Err()
+}
+
+// Collect 收集流水线的所有输出, 返回流水线的第一个错误, 出错时返回出错前已经输出的元素
+func Collect[T any](s *Stream[T]) ([]T, error) {
+	var res []T
+	err := ForEach(s, func(v T) {
+		res = append(res, v)
+	})
+	return res, err
+}
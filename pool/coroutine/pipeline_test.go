@@ -0,0 +1,155 @@
+package coroutine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline_ordered(t *testing.T) {
+	pool := NewRoutinePool(4)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	squared := Map(FromSlice(p, items), func(ctx context.Context, v int) (int, error) {
+		// 越早的元素处理越慢, 按完成顺序输出时顺序会被打乱
+		time.Sleep(time.Duration(100-v) * 10 * time.Microsecond)
+		return v * v, nil
+	}, WithStageWorkers(8), WithOrdered())
+	formatted := Map(squared, func(ctx context.Context, v int) (string, error) {
+		return strconv.Itoa(v), nil
+	}, WithStageWorkers(2), WithStageBuffer(4), WithOrdered())
+
+	res, err := Collect(formatted)
+	assert.NoError(t, err)
+	assert.Len(t, res, 100)
+	for i, v := range res {
+		assert.Equal(t, strconv.Itoa(i*i), v)
+	}
+}
+
+func TestPipeline_unordered(t *testing.T) {
+	pool := NewRoutinePool(4)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+
+	var running, maxRunning int32
+	res, err := Collect(Map(FromSlice(p, []int{1, 2, 3, 4, 5, 6, 7, 8}), func(ctx context.Context, v int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return v, nil
+	}, WithStageWorkers(3)))
+	assert.NoError(t, err)
+	sort.Ints(res)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8}, res)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(3))
+}
+
+func TestPipeline_error_cancels_upstream(t *testing.T) {
+	pool := NewRoutinePool(2)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+
+	source := make(chan int)
+	var produced int32
+	go func() {
+		defer close(source)
+		for i := 0; ; i++ {
+			select {
+			case source <- i:
+				atomic.AddInt32(&produced, 1)
+			case <-p.Context().Done():
+				return
+			}
+		}
+	}()
+	errBoom := errors.New("boom")
+	stage := Map(FromChan(p, source), func(ctx context.Context, v int) (int, error) {
+		if v == 10 {
+			return 0, errBoom
+		}
+		return v, nil
+	})
+	_, err := Collect(stage)
+	assert.Equal(t, errBoom, err)
+	assert.Less(t, atomic.LoadInt32(&produced), int32(20))
+}
+
+func TestPipeline_panic(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+	_, err := Collect(Map(FromSlice(p, []int{1}), func(ctx context.Context, v int) (int, error) {
+		panic("boom")
+	}))
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+}
+
+func TestPipeline_parent_cancelled(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPipeline(ctx, pool)
+	stage := Map(FromChan(p, make(chan int)), func(ctx context.Context, v int) (int, error) {
+		return v, nil
+	})
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := Collect(stage)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestPipeline_Batch_by_size(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+	res, err := Collect(Batch(FromSlice(p, []int{1, 2, 3, 4, 5, 6, 7}), 3, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}, res)
+}
+
+func TestPipeline_Batch_by_time(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	p := NewPipeline(context.Background(), pool)
+
+	source := make(chan int)
+	go func() {
+		defer close(source)
+		source <- 1
+		source <- 2
+		time.Sleep(50 * time.Millisecond)
+		source <- 3
+	}()
+	sums := Map(Batch(FromChan(p, source), 10, 10*time.Millisecond), func(ctx context.Context, batch []int) (int, error) {
+		sum := 0
+		for _, v := range batch {
+			sum += v
+		}
+		return sum, nil
+	})
+	var res []int
+	err := ForEach(sums, func(v int) {
+		res = append(res, v)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 3}, res)
+}
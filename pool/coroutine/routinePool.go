@@ -1,46 +1,228 @@
 package coroutine
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/koleter/go-util/list/linkedlist"
-	"log"
+	"github.com/koleter/go-util/g"
 	"runtime/debug"
 	"sync"
+	"time"
 )
 
+var (
+	// ErrQueueFull 任务队列已满, 任务被拒绝
+	ErrQueueFull = errors.New("routine pool task queue is full")
+	// ErrTaskDiscarded 任务因队列已满被丢弃
+	ErrTaskDiscarded = errors.New("routine pool task is discarded")
+)
+
+// RejectPolicy 任务队列已满时的拒绝策略
+type RejectPolicy int
+
+const (
+	// Abort 拒绝新任务并返回 ErrQueueFull
+	Abort RejectPolicy = iota
+	// Block 阻塞等待队列有空位
+	Block
+	// CallerRuns 在提交任务的协程中直接执行新任务
+	CallerRuns
+	// DiscardOldest 丢弃队列中优先级最低的任务中最老的任务, 再将新任务入队
+	DiscardOldest
+	// DiscardNewest 丢弃新任务并返回 ErrTaskDiscarded
+	DiscardNewest
+)
+
+// Option 协程池的配置项
+type Option func(*options)
+
+type options struct {
+	queueCapacity int
+	rejectPolicy  RejectPolicy
+	maxSize       int
+	keepAlive     time.Duration
+	classWeights  map[string]int
+	panicHandler  PanicHandler
+	beforeTask    []func()
+	afterTask     []func(elapsed time.Duration)
+	wrappers      []TaskWrapper
+	workerStart   []func()
+	workerStop    []func()
+	inherit       bool
+}
+
+// defaultKeepAlive 超过核心数量的工作协程默认的空闲存活时间
+const defaultKeepAlive = time.Minute
+
+// WithMaxWorkers 设置工作协程的最大数量, 默认与核心数量相同;
+// 任务积压时会创建超过核心数量的工作协程, 它们空闲超过存活时间后退出
+func WithMaxWorkers(maxSize int) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+// WithKeepAlive 设置超过核心数量的工作协程的空闲存活时间, 默认为1分钟
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = keepAlive
+	}
+}
+
+// WithClassWeight 设置任务类别的权重, 默认为1; 同一优先级内各类别按权重比例获得执行机会,
+// 大量提交的类别不会让其他类别饿死
+func WithClassWeight(class string, weight int) Option {
+	return func(o *options) {
+		if weight <= 0 {
+			panic(fmt.Sprintf("weight is less than or equal to 0, weight: %d", weight))
+		}
+		if o.classWeights == nil {
+			o.classWeights = map[string]int{}
+		}
+		o.classWeights[class] = weight
+	}
+}
+
+// WithInheritableThreadLocals 提交任务时捕获提交协程的 g.InheritableThreadLocal 的值, 任务执行期间在工作协程中恢复
+func WithInheritableThreadLocals() Option {
+	return func(o *options) {
+		o.inherit = true
+	}
+}
+
+// WithQueueCapacity 设置任务队列的容量, 小于等于0时不限制
+func WithQueueCapacity(capacity int) Option {
+	return func(o *options) {
+		o.queueCapacity = capacity
+	}
+}
+
+// WithRejectPolicy 设置任务队列已满时的拒绝策略, 默认为 Abort
+func WithRejectPolicy(policy RejectPolicy) Option {
+	return func(o *options) {
+		o.rejectPolicy = policy
+	}
+}
+
+// Stats 协程池的运行统计
+type Stats struct {
+	CoreSize  int    // 核心工作协程数量
+	MaxSize   int    // 最大工作协程数量
+	Workers   int    // 当前工作协程数量
+	Active    int    // 正在执行任务的工作协程数量
+	Idle      int    // 空闲的工作协程数量
+	Queued    int    // 队列中等待执行的任务数量
+	Completed uint64 // 累计执行完毕的任务数量
+	Rejected  uint64 // 累计触发拒绝策略的次数
+}
+
 type RoutinePool struct {
-	tasks   linkedlist.LinkedList[func()]
-	wg      sync.WaitGroup
-	mu      sync.Mutex
-	cond    *sync.Cond
-	stopped bool
+	opts       options
+	tasks      taskQueue
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	cond       *sync.Cond
+	notFull    *sync.Cond
+	stopped    bool
+	terminated chan struct{} // 停止后所有工作协程退出时关闭
+	ctx        context.Context
+	cancel     context.CancelFunc
+	coreSize   int
+	workers    int
+	active     int
+	idle       int
+
+	completed uint64
+	rejected  uint64
 }
 
-// NewRoutinePool 创建一个新的实例
-func NewRoutinePool(workerNum int) *RoutinePool {
+// NewRoutinePool 创建一个新的实例, workerNum为核心工作协程数量, 核心工作协程会立即启动且不会因空闲而退出
+func NewRoutinePool(workerNum int, opts ...Option) *RoutinePool {
 	if workerNum <= 0 {
 		panic(fmt.Sprintf("workerNum is less than or equal to 0, workerNum: %d", workerNum))
 	}
 	pool := &RoutinePool{
-		tasks: linkedlist.LinkedList[func()]{},
+		coreSize:   workerNum,
+		terminated: make(chan struct{}),
+	}
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(&pool.opts)
+	}
+	pool.tasks = newTaskQueue(pool.opts.classWeights)
+	if pool.opts.maxSize < workerNum {
+		pool.opts.maxSize = workerNum
+	}
+	if pool.opts.panicHandler == nil {
+		pool.opts.panicHandler = defaultPanicHandler
+	}
+	if pool.opts.keepAlive <= 0 {
+		pool.opts.keepAlive = defaultKeepAlive
 	}
 	pool.cond = sync.NewCond(&pool.mu)
+	pool.notFull = sync.NewCond(&pool.mu)
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
 	for i := 0; i < workerNum; i++ {
-		pool.wg.Add(1)
-		go pool.worker()
+		pool.addWorker(nil)
 	}
 	return pool
 }
 
-// Stop 停止协程池不再接收新任务
+// SetCoreSize 修改核心工作协程数量, 增大时立即启动新的工作协程, 减小时多余的工作协程空闲后按存活时间退出
+func (p *RoutinePool) SetCoreSize(coreSize int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if coreSize <= 0 || coreSize > p.opts.maxSize {
+		panic(fmt.Sprintf("coreSize must be in (0, maxSize], coreSize: %d, maxSize: %d", coreSize, p.opts.maxSize))
+	}
+	p.coreSize = coreSize
+	for !p.stopped && p.workers < p.coreSize {
+		p.addWorker(nil)
+	}
+	p.cond.Broadcast()
+}
+
+// SetMaxSize 修改工作协程的最大数量, 减小时多余的工作协程在空闲时退出
+func (p *RoutinePool) SetMaxSize(maxSize int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if maxSize < p.coreSize {
+		panic(fmt.Sprintf("maxSize is less than coreSize, maxSize: %d, coreSize: %d", maxSize, p.coreSize))
+	}
+	p.opts.maxSize = maxSize
+	p.cond.Broadcast()
+}
+
+// Stats 返回运行统计
+func (p *RoutinePool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		CoreSize:  p.coreSize,
+		MaxSize:   p.opts.maxSize,
+		Workers:   p.workers,
+		Active:    p.active,
+		Idle:      p.idle,
+		Queued:    p.tasks.Len(),
+		Completed: p.completed,
+		Rejected:  p.rejected,
+	}
+}
+
+// Stop 停止协程池不再接收新任务, 已提交的任务会继续执行
 func (p *RoutinePool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.stopped {
 		return
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
 	p.stopped = true
+	if p.workers == 0 {
+		close(p.terminated)
+	}
 	p.cond.Broadcast()
+	p.notFull.Broadcast()
 }
 
 // Wait 等待所有任务执行完毕,调用该函数前必须调用 Stop
@@ -48,42 +230,286 @@ func (p *RoutinePool) Wait() {
 	p.wg.Wait()
 }
 
-func runTask(task func()) {
+// Shutdown 停止协程池并等待正在执行与队列中的任务执行完毕,
+// ctx先结束时取消任务的上下文以通知正在执行的任务, 并返回ctx.Err(), 队列中的任务仍会继续执行
+func (p *RoutinePool) Shutdown(ctx context.Context) error {
+	p.Stop()
+	if err := p.AwaitTermination(ctx); err != nil {
+		p.cancel()
+		return err
+	}
+	return nil
+}
+
+// ShutdownNow 停止协程池, 取消任务的上下文以通知正在执行的任务, 并返回队列中还未开始执行的任务.
+// 通过 SubmitFunc 提交的任务同样会被返回, 不执行返回的函数时可以调用 Future.Cancel 结束等待
+func (p *RoutinePool) ShutdownNow() []func() {
+	p.Stop()
+	p.cancel()
+	p.mu.Lock()
+	tasks := make([]func(), 0, p.tasks.Len())
+	var dropped []func()
+	for t, ok := p.tasks.Pop(); ok; t, ok = p.tasks.Pop() {
+		tasks = append(tasks, t.run)
+		if t.dropped != nil {
+			dropped = append(dropped, t.dropped)
+		}
+	}
+	p.mu.Unlock()
+	for _, f := range dropped {
+		f()
+	}
+	return tasks
+}
+
+// AwaitTermination 等待协程池停止后所有工作协程退出, ctx先结束时返回ctx.Err()
+func (p *RoutinePool) AwaitTermination(ctx context.Context) error {
+	select {
+	case <-p.terminated:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsShutdown 协程池是否已经停止接收新任务
+func (p *RoutinePool) IsShutdown() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+// IsTerminated 协程池是否已经停止且所有工作协程都已退出
+func (p *RoutinePool) IsTerminated() bool {
+	select {
+	case <-p.terminated:
+		return true
+	default:
+		return false
+	}
+}
+
+// Context 任务的上下文, 在 ShutdownNow 或 Shutdown 超时时被取消
+func (p *RoutinePool) Context() context.Context {
+	return p.ctx
+}
+
+// runTask 执行任务, panic时交给handler处理
+func runTask(task func(), handler PanicHandler) {
 	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("Task panicked: %v\nstack: %s", err, string(debug.Stack()))
+		if r := recover(); r != nil {
+			handler(r, debug.Stack())
 		}
 	}()
 	task()
 }
 
+// addWorker 启动一个工作协程, first不为nil时作为它的第一个任务, 调用前需要持有 p.mu
+func (p *RoutinePool) addWorker(first *task) {
+	p.workers++
+	if first != nil {
+		p.active++
+	}
+	p.wg.Add(1)
+	go p.worker(first)
+}
+
 // worker 是每个工作者的主循环
-func (p *RoutinePool) worker() {
+func (p *RoutinePool) worker(t *task) {
 	defer p.wg.Done()
-	for !p.stopped || p.tasks.Len() != 0 {
+	for _, f := range p.opts.workerStart {
+		f()
+	}
+	defer func() {
+		for _, f := range p.opts.workerStop {
+			f()
+		}
+	}()
+	if t == nil {
 		p.mu.Lock()
-		if p.tasks.Len() == 0 {
-			if p.stopped {
-				p.mu.Unlock()
-				return
+		t = p.getTask()
+		p.mu.Unlock()
+	}
+	for t != nil {
+		p.runTask(t.run)
+		t = p.nextTask()
+	}
+}
+
+// nextTask 结束当前任务并获取下一个任务, 返回nil时工作协程应当退出
+func (p *RoutinePool) nextTask() *task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.completed++
+	return p.getTask()
+}
+
+// getTask 获取下一个任务, 没有任务时等待, 返回nil时工作协程应当退出, 调用前需要持有 p.mu
+func (p *RoutinePool) getTask() *task {
+	var deadline time.Time
+	for {
+		if t, ok := p.tasks.Pop(); ok {
+			p.notFull.Signal()
+			p.active++
+			return t
+		}
+		// 已停止且任务已经全部执行完毕, 或者工作协程数量超过了上限
+		if p.stopped || p.workers > p.opts.maxSize {
+			p.exitWorker()
+			return nil
+		}
+		if p.workers > p.coreSize {
+			now := time.Now()
+			if deadline.IsZero() {
+				deadline = now.Add(p.opts.keepAlive)
+				timer := time.AfterFunc(p.opts.keepAlive, p.wakeAll)
+				defer timer.Stop()
+			} else if !now.Before(deadline) {
+				p.exitWorker()
+				return nil
 			}
-			p.cond.Wait()
 		}
-		task, ok := p.tasks.Pop()
+		p.idle++
+		p.cond.Wait()
+		p.idle--
+	}
+}
+
+// exitWorker 工作协程退出, 调用前需要持有 p.mu
+func (p *RoutinePool) exitWorker() {
+	p.workers--
+	if p.stopped && p.workers == 0 {
+		close(p.terminated)
+	}
+}
+
+// wakeAll 唤醒所有空闲的工作协程
+func (p *RoutinePool) wakeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// Submit 提交一个任务, 协程池已停止时返回 ErrPoolStopped, 队列已满时按拒绝策略处理
+func (p *RoutinePool) Submit(task func()) error {
+	return p.submit(task, nil)
+}
+
+// SubmitContext 提交一个可以感知取消的任务, 任务接收协程池的上下文
+func (p *RoutinePool) SubmitContext(task func(ctx context.Context)) error {
+	return p.submit(func() { task(p.ctx) }, nil)
+}
+
+// SubmitPriority 以指定的优先级提交一个任务, 工作协程总是先执行优先级高的任务, Submit 提交的任务优先级为0
+func (p *RoutinePool) SubmitPriority(priority int, run func()) error {
+	return p.submitTask(&task{run: run, priority: priority})
+}
+
+// SubmitClass 以指定的任务类别与优先级提交一个任务, 同一优先级内各类别按 WithClassWeight 设置的权重公平执行,
+// Submit 提交的任务类别为空字符串
+func (p *RoutinePool) SubmitClass(class string, priority int, run func()) error {
+	return p.submitTask(&task{run: run, priority: priority, class: class})
+}
+
+// submit 提交一个任务, reject在任务入队后又被丢弃时调用
+func (p *RoutinePool) submit(run func(), reject func(err error)) error {
+	return p.submitTask(&task{run: run, reject: reject})
+}
+
+// submitCaptured 提交一个已经通过 capture 捕获了提交者的值的任务, 用于在内部协程中代替提交者提交任务
+func (p *RoutinePool) submitCaptured(run func(), reject func(err error)) error {
+	return p.submitTask(&task{run: run, reject: reject, captured: true})
+}
+
+// capture 开启 WithInheritableThreadLocals 时捕获当前协程的 g.InheritableThreadLocal 的值, 返回的函数执行期间恢复这些值
+func (p *RoutinePool) capture(run func()) func() {
+	if p.opts.inherit {
+		return g.Wrap(run)
+	}
+	return run
+}
+
+func (p *RoutinePool) submitTask(t *task) error {
+	if p.opts.inherit && !t.captured {
+		t.run = g.Wrap(t.run)
+	}
+	p.mu.Lock()
+	if p.stopped {
 		p.mu.Unlock()
-		if !ok {
-			continue
+		return ErrPoolStopped
+	}
+	if p.full() {
+		if p.workers < p.opts.maxSize {
+			// 队列已满时直接交给新的工作协程执行
+			p.addWorker(t)
+			p.mu.Unlock()
+			return nil
+		}
+		if p.opts.rejectPolicy != Block {
+			p.rejected++
+		}
+		switch p.opts.rejectPolicy {
+		case Block:
+			for p.full() && !p.stopped {
+				p.notFull.Wait()
+			}
+			if p.stopped {
+				p.mu.Unlock()
+				return ErrPoolStopped
+			}
+		case CallerRuns:
+			p.mu.Unlock()
+			p.runTask(t.run)
+			return nil
+		case DiscardOldest:
+			if oldest, ok := p.tasks.PopOldest(); ok && oldest.reject != nil {
+				defer oldest.reject(ErrTaskDiscarded)
+			}
+		case DiscardNewest:
+			p.mu.Unlock()
+			return ErrTaskDiscarded
+		default:
+			p.mu.Unlock()
+			return ErrQueueFull
 		}
-		runTask(task)
 	}
+	p.enqueue(t)
+	p.mu.Unlock()
+	return nil
 }
 
-// Submit 提交一个任务
-func (p *RoutinePool) Submit(task func()) {
+// offer 尝试提交一个任务, 协程池已停止或队列已满时不执行拒绝策略而是直接返回false,
+// 用于工作协程内提交后续任务, 避免 Block 策略下所有工作协程互相等待
+func (p *RoutinePool) offer(t *task) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if !p.stopped {
-		p.tasks.Append(task)
+	if p.stopped {
+		return false
+	}
+	if p.full() {
+		if p.workers < p.opts.maxSize {
+			p.addWorker(t)
+			return true
+		}
+		return false
+	}
+	p.enqueue(t)
+	return true
+}
+
+// enqueue 将任务加入队列并唤醒或创建工作协程, 调用前需要持有 p.mu
+func (p *RoutinePool) enqueue(t *task) {
+	p.tasks.Append(t)
+	if p.tasks.Len() > p.idle && p.workers < p.opts.maxSize {
+		// 空闲的工作协程不足以处理积压的任务
+		p.addWorker(nil)
+	} else {
 		p.cond.Signal()
 	}
 }
+
+// full 任务队列是否已满, 调用前需要持有 p.mu
+func (p *RoutinePool) full() bool {
+	return p.opts.queueCapacity > 0 && p.tasks.Len() >= p.opts.queueCapacity
+}
@@ -1,6 +1,10 @@
 package coroutine
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -56,3 +60,259 @@ func TestWorker_ExecutesTasks(t *testing.T) {
 	pool.Stop()
 	pool.Wait()
 }
+
+func TestSubmit_WhenStopped_ReturnsError(t *testing.T) {
+	pool := NewRoutinePool(1)
+	pool.Stop()
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrPoolStopped) {
+		t.Errorf("Expected ErrPoolStopped, got %v", err)
+	}
+}
+
+// blockPool 创建一个唯一的工作协程被阻塞的协程池, 关闭返回的channel后恢复
+func blockPool(t *testing.T, opts ...Option) (*RoutinePool, chan struct{}) {
+	pool := NewRoutinePool(1, opts...)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	if err := pool.Submit(func() {
+		close(started)
+		<-block
+	}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+	return pool, block
+}
+
+func TestRejectPolicy_Abort(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1))
+	if err := pool.Submit(func() {}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestRejectPolicy_Block(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1), WithRejectPolicy(Block))
+	pool.Submit(func() {})
+	submitted := make(chan error)
+	go func() {
+		submitted <- pool.Submit(func() {})
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit should block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(block)
+	if err := <-submitted; err != nil {
+		t.Errorf("Submit failed: %v", err)
+	}
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestRejectPolicy_CallerRuns(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1), WithRejectPolicy(CallerRuns))
+	pool.Submit(func() {})
+	ran := false
+	if err := pool.Submit(func() { ran = true }); err != nil {
+		t.Errorf("Submit failed: %v", err)
+	}
+	if !ran {
+		t.Error("Task should run in the caller goroutine")
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestRejectPolicy_DiscardOldest(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1), WithRejectPolicy(DiscardOldest))
+	oldest := SubmitFunc(pool, func() (int, error) { return 1, nil })
+	newest := SubmitFunc(pool, func() (int, error) { return 2, nil })
+	if _, err := oldest.Get(); !errors.Is(err, ErrTaskDiscarded) {
+		t.Errorf("Expected ErrTaskDiscarded, got %v", err)
+	}
+	close(block)
+	if v, err := newest.Get(); err != nil || v != 2 {
+		t.Errorf("Expected 2, got %d, %v", v, err)
+	}
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestRejectPolicy_DiscardNewest(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1), WithRejectPolicy(DiscardNewest))
+	pool.Submit(func() {})
+	if err := pool.Submit(func() {
+		t.Error("Discarded task should not be executed")
+	}); !errors.Is(err, ErrTaskDiscarded) {
+		t.Errorf("Expected ErrTaskDiscarded, got %v", err)
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+}
+
+// waitFor 轮询直到cond满足或超时
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not satisfied before timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRoutinePool_spawn_extra_workers_and_reap(t *testing.T) {
+	pool := NewRoutinePool(1, WithMaxWorkers(3), WithKeepAlive(20*time.Millisecond))
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		pool.Submit(func() { <-block })
+	}
+	waitFor(t, func() bool {
+		stats := pool.Stats()
+		return stats.Workers == 3 && stats.Active == 3
+	})
+	// 达到最大数量后任务进入队列
+	pool.Submit(func() { <-block })
+	if stats := pool.Stats(); stats.Workers != 3 || stats.Queued != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	close(block)
+	// 空闲超过存活时间后退回到核心数量
+	waitFor(t, func() bool {
+		return pool.Stats().Workers == 1
+	})
+	if stats := pool.Stats(); stats.Completed != 4 || stats.Idle != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestRoutinePool_SetCoreSize_SetMaxSize(t *testing.T) {
+	pool := NewRoutinePool(1, WithKeepAlive(20*time.Millisecond))
+	pool.SetMaxSize(4)
+	pool.SetCoreSize(3)
+	waitFor(t, func() bool {
+		return pool.Stats().Idle == 3
+	})
+	pool.SetCoreSize(2)
+	waitFor(t, func() bool {
+		return pool.Stats().Workers == 2
+	})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected a panic when maxSize is less than coreSize")
+		}
+		pool.Stop()
+		pool.Wait()
+	}()
+	pool.SetMaxSize(1)
+}
+
+func TestRoutinePool_Stats_rejected(t *testing.T) {
+	pool, block := blockPool(t, WithQueueCapacity(1))
+	pool.Submit(func() {})
+	pool.Submit(func() {})
+	pool.Submit(func() {})
+	if stats := pool.Stats(); stats.Rejected != 2 || stats.Queued != 1 || stats.Active != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+}
+
+func TestShutdown_waits_for_queued_tasks(t *testing.T) {
+	pool := NewRoutinePool(1)
+	var done int32
+	for i := 0; i < 5; i++ {
+		pool.Submit(func() {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		})
+	}
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if atomic.LoadInt32(&done) != 5 {
+		t.Errorf("Expected 5 tasks done, got %d", done)
+	}
+	if !pool.IsShutdown() || !pool.IsTerminated() {
+		t.Error("Pool should be terminated")
+	}
+}
+
+func TestShutdown_deadline_cancels_running_tasks(t *testing.T) {
+	pool := NewRoutinePool(1)
+	cancelled := make(chan struct{})
+	pool.SubmitContext(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	<-cancelled
+	if err := pool.AwaitTermination(context.Background()); err != nil {
+		t.Errorf("AwaitTermination failed: %v", err)
+	}
+}
+
+func TestShutdownNow_returns_pending_tasks(t *testing.T) {
+	pool := NewRoutinePool(1)
+	started := make(chan struct{})
+	pool.SubmitContext(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+	var ran int32
+	for i := 0; i < 3; i++ {
+		pool.Submit(func() {
+			atomic.AddInt32(&ran, 1)
+		})
+	}
+	pending := pool.ShutdownNow()
+	if len(pending) != 3 {
+		t.Fatalf("Expected 3 pending tasks, got %d", len(pending))
+	}
+	if err := pool.AwaitTermination(context.Background()); err != nil {
+		t.Fatalf("AwaitTermination failed: %v", err)
+	}
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Error("Pending tasks should not be executed")
+	}
+	for _, task := range pending {
+		task()
+	}
+	if atomic.LoadInt32(&ran) != 3 {
+		t.Error("Returned tasks should be runnable")
+	}
+}
+
+func TestStop_concurrent(t *testing.T) {
+	pool := NewRoutinePool(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Stop()
+		}()
+	}
+	wg.Wait()
+	pool.Wait()
+}
@@ -0,0 +1,358 @@
+package coroutine
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerStopped 定时任务执行器已停止, 任务没有被接收
+var ErrSchedulerStopped = errors.New("scheduled executor is stopped")
+
+// Timer 由 Clock 创建的定时器
+type Timer interface {
+	// Stop 停止定时器, 定时器已经触发或已经停止时返回false
+	Stop() bool
+}
+
+// Clock 时钟, 测试时可以替换为手动推进的实现, 使定时任务的测试不需要真正等待
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 在d时长后在新的协程中调用f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+const (
+	scheduledWaiting   = iota // 在队列中等待到期
+	scheduledRunning          // 已提交给协程池
+	scheduledDone             // 已执行完毕或因panic, 拒绝而结束
+	scheduledCancelled        // 已取消
+)
+
+// ScheduledTask 定时任务的句柄
+type ScheduledTask struct {
+	executor *ScheduledExecutor
+	fn       func()
+	// period 大于0时以固定频率执行, 小于0时以固定间隔执行, 等于0时只执行一次
+	period time.Duration
+	at     time.Time
+	seq    uint64 // 入队序号, 到期时间相同时先入队的先执行
+	index  int    // 在堆中的下标
+	state  int
+	err    error
+	done   chan struct{}
+}
+
+// Cancel 取消任务, 正在执行的任务会执行完毕但不会再次执行, 任务已经结束时返回false
+func (t *ScheduledTask) Cancel() bool {
+	e := t.executor
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch t.state {
+	case scheduledWaiting:
+		heap.Remove(&e.tasks, t.index)
+		e.arm()
+	case scheduledRunning:
+	default:
+		return false
+	}
+	t.state = scheduledCancelled
+	t.err = ErrCancelled
+	close(t.done)
+	return true
+}
+
+// IsCancelled 任务是否已被取消
+func (t *ScheduledTask) IsCancelled() bool {
+	e := t.executor
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return t.state == scheduledCancelled
+}
+
+// Delay 距离下一次执行的时长, 已到期或已结束时返回值小于等于0
+func (t *ScheduledTask) Delay() time.Duration {
+	e := t.executor
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t.state != scheduledWaiting {
+		return 0
+	}
+	return t.at.Sub(e.clock.Now())
+}
+
+// Done 返回一个在任务结束时关闭的通道, 周期任务只会因取消, panic或执行器停止而结束
+func (t *ScheduledTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err 任务结束的原因, 取消或执行器停止时为 ErrCancelled, panic时为 *PanicError,
+// 协程池拒绝任务时为对应的错误, 任务还未结束或正常结束时为nil
+func (t *ScheduledTask) Err() error {
+	e := t.executor
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return t.err
+}
+
+// ScheduledExecutor 定时任务执行器, 按到期时间排序等待执行的任务, 到期后交给 RoutinePool 的工作协程执行
+type ScheduledExecutor struct {
+	pool    *RoutinePool
+	clock   Clock
+	mu      sync.Mutex
+	tasks   scheduledHeap
+	timer   Timer
+	timerAt time.Time // timer的触发时间
+	// timerGen 每次设置或停止定时器时加1, 停止失败的旧定时器触发时据此识别并忽略
+	timerGen uint64
+	seq      uint64
+	stopped  bool
+}
+
+// NewScheduledExecutor 创建一个新的实例, 任务在pool中执行, clock为nil时使用系统时钟
+func NewScheduledExecutor(pool *RoutinePool, clock Clock) *ScheduledExecutor {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &ScheduledExecutor{
+		pool:  pool,
+		clock: clock,
+	}
+}
+
+// Schedule 在delay时长后执行一次fn
+func (e *ScheduledExecutor) Schedule(delay time.Duration, fn func()) (*ScheduledTask, error) {
+	return e.schedule(delay, 0, fn)
+}
+
+// ScheduleAtFixedRate 在initialDelay时长后第一次执行fn, 之后每隔period时长执行一次;
+// 执行耗时超过period时下一次执行会推迟, 但同一个任务不会同时执行
+func (e *ScheduledExecutor) ScheduleAtFixedRate(initialDelay, period time.Duration, fn func()) (*ScheduledTask, error) {
+	if period <= 0 {
+		panic(fmt.Sprintf("period is less than or equal to 0, period: %v", period))
+	}
+	return e.schedule(initialDelay, period, fn)
+}
+
+// ScheduleWithFixedDelay 在initialDelay时长后第一次执行fn, 之后每次执行结束delay时长后再次执行
+func (e *ScheduledExecutor) ScheduleWithFixedDelay(initialDelay, delay time.Duration, fn func()) (*ScheduledTask, error) {
+	if delay <= 0 {
+		panic(fmt.Sprintf("delay is less than or equal to 0, delay: %v", delay))
+	}
+	return e.schedule(initialDelay, -delay, fn)
+}
+
+// Len 等待到期的任务数量
+func (e *ScheduledExecutor) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.tasks.Len()
+}
+
+// Stop 停止执行器, 取消所有等待到期的任务, 正在执行的周期任务执行完毕后不会再次执行; 不会停止协程池
+func (e *ScheduledExecutor) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	e.stopped = true
+	e.stopTimer()
+	for e.tasks.Len() > 0 {
+		t := heap.Pop(&e.tasks).(*ScheduledTask)
+		e.finish(t, ErrCancelled)
+	}
+}
+
+func (e *ScheduledExecutor) schedule(delay, period time.Duration, fn func()) (*ScheduledTask, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil, ErrSchedulerStopped
+	}
+	// 任务由定时器的协程提交, 在这里捕获调度者的 g.InheritableThreadLocal 的值
+	fn = e.pool.capture(fn)
+	t := &ScheduledTask{
+		executor: e,
+		fn:       fn,
+		period:   period,
+		at:       e.clock.Now().Add(delay),
+		done:     make(chan struct{}),
+	}
+	e.push(t)
+	return t, nil
+}
+
+// arm 按最早到期的任务设置定时器, 调用前需要持有 e.mu
+func (e *ScheduledExecutor) arm() {
+	if e.tasks.Len() == 0 {
+		e.stopTimer()
+		return
+	}
+	at := e.tasks[0].at
+	if e.timer != nil && e.timerAt.Equal(at) {
+		return
+	}
+	e.stopTimer()
+	gen := e.timerGen
+	e.timerAt = at
+	e.timer = e.clock.AfterFunc(at.Sub(e.clock.Now()), func() {
+		e.fire(gen)
+	})
+}
+
+// stopTimer 停止当前的定时器, 调用前需要持有 e.mu;
+// Stop 失败时定时器可能正在触发, 增加 timerGen 使它触发时被忽略
+func (e *ScheduledExecutor) stopTimer() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.timerGen++
+}
+
+// fire 将所有到期的任务交给协程池执行, gen不是当前定时器的代数时说明定时器已被替换, 不做任何操作
+func (e *ScheduledExecutor) fire(gen uint64) {
+	e.mu.Lock()
+	if e.stopped || gen != e.timerGen {
+		e.mu.Unlock()
+		return
+	}
+	now := e.clock.Now()
+	var due []*ScheduledTask
+	for e.tasks.Len() > 0 && !e.tasks[0].at.After(now) {
+		t := heap.Pop(&e.tasks).(*ScheduledTask)
+		t.state = scheduledRunning
+		due = append(due, t)
+	}
+	e.timer = nil
+	e.arm()
+	e.mu.Unlock()
+
+	// 在锁外提交, 拒绝策略为 Block 时提交可能阻塞
+	for _, t := range due {
+		t := t
+		reject := func(err error) {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if t.state == scheduledRunning {
+				e.finish(t, err)
+			}
+		}
+		if err := e.pool.submitCaptured(func() { e.run(t) }, reject); err != nil {
+			reject(err)
+		}
+	}
+}
+
+// run 在工作协程中执行任务, 周期任务执行完毕后重新入队
+func (e *ScheduledExecutor) run(t *ScheduledTask) {
+	e.mu.Lock()
+	cancelled := t.state != scheduledRunning
+	e.mu.Unlock()
+	if cancelled {
+		return
+	}
+
+	err := callScheduled(t.fn)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t.state != scheduledRunning {
+		// 执行期间被取消
+		return
+	}
+	if err != nil || t.period == 0 {
+		e.finish(t, err)
+		return
+	}
+	if e.stopped {
+		e.finish(t, ErrCancelled)
+		return
+	}
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+	} else {
+		t.at = e.clock.Now().Add(-t.period)
+	}
+	t.state = scheduledWaiting
+	e.push(t)
+}
+
+// push 将任务加入队列, 调用前需要持有 e.mu
+func (e *ScheduledExecutor) push(t *ScheduledTask) {
+	e.seq++
+	t.seq = e.seq
+	heap.Push(&e.tasks, t)
+	e.arm()
+}
+
+// finish 结束任务, 调用前需要持有 e.mu
+func (e *ScheduledExecutor) finish(t *ScheduledTask, err error) {
+	if err == ErrCancelled {
+		t.state = scheduledCancelled
+	} else {
+		t.state = scheduledDone
+	}
+	t.err = err
+	close(t.done)
+}
+
+func callScheduled(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// scheduledHeap 按到期时间排序的最小堆, 到期时间相同时按入队顺序
+type scheduledHeap []*ScheduledTask
+
+func (h scheduledHeap) Len() int {
+	return len(h)
+}
+
+func (h scheduledHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h scheduledHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduledHeap) Push(x any) {
+	t := x.(*ScheduledTask)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *scheduledHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
@@ -0,0 +1,316 @@
+package coroutine
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	if d <= 0 {
+		go f()
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance 推进时钟并按时间顺序同步触发到期的定时器
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.Slice(c.timers, func(i, j int) bool {
+			return c.timers[i].at.Before(c.timers[j].at)
+		})
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func TestScheduledExecutor_Schedule(t *testing.T) {
+	pool := NewRoutinePool(2)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var ran int32
+	task, err := executor.Schedule(time.Second, func() {
+		atomic.AddInt32(&ran, 1)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, task.Delay())
+
+	clock.Advance(999 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+	clock.Advance(time.Millisecond)
+	<-task.Done()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ran))
+	assert.NoError(t, task.Err())
+	assert.False(t, task.Cancel())
+	assert.Equal(t, 0, executor.Len())
+}
+
+func TestScheduledExecutor_order(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var mu sync.Mutex
+	var order []int
+	var tasks []*ScheduledTask
+	for i, delay := range []time.Duration{3, 1, 2, 1} {
+		i := i
+		task, _ := executor.Schedule(delay*time.Second, func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+		tasks = append(tasks, task)
+	}
+	clock.Advance(3 * time.Second)
+	for _, task := range tasks {
+		<-task.Done()
+	}
+	assert.Equal(t, []int{1, 3, 2, 0}, order)
+}
+
+func TestScheduledExecutor_Cancel(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var ran int32
+	task, _ := executor.Schedule(time.Second, func() {
+		atomic.AddInt32(&ran, 1)
+	})
+	assert.True(t, task.Cancel())
+	assert.True(t, task.IsCancelled())
+	assert.Equal(t, ErrCancelled, task.Err())
+	assert.False(t, task.Cancel())
+	assert.Equal(t, 0, executor.Len())
+
+	clock.Advance(2 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+}
+
+func TestScheduledExecutor_ScheduleAtFixedRate(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var ran int32
+	task, _ := executor.ScheduleAtFixedRate(time.Second, time.Second, func() {
+		atomic.AddInt32(&ran, 1)
+	})
+	for i := 1; i <= 5; i++ {
+		clock.Advance(time.Second)
+		i := int32(i)
+		// 等待本次执行结束并重新入队
+		waitFor(t, func() bool {
+			return atomic.LoadInt32(&ran) == i && executor.Len() == 1
+		})
+		assert.Equal(t, time.Second, task.Delay())
+	}
+	assert.True(t, task.Cancel())
+	clock.Advance(5 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&ran))
+}
+
+func TestScheduledExecutor_ScheduleWithFixedDelay(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var ran int32
+	task, _ := executor.ScheduleWithFixedDelay(0, time.Second, func() {
+		// 模拟耗时500ms的任务
+		clock.mu.Lock()
+		clock.now = clock.now.Add(500 * time.Millisecond)
+		clock.mu.Unlock()
+		atomic.AddInt32(&ran, 1)
+	})
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&ran) == 1 && executor.Len() == 1
+	})
+	// 下一次执行在上一次结束1秒后
+	assert.Equal(t, time.Second, task.Delay())
+	clock.Advance(time.Second)
+	waitFor(t, func() bool {
+		return atomic.LoadInt32(&ran) == 2 && executor.Len() == 1
+	})
+	assert.Equal(t, time.Second, task.Delay())
+}
+
+func TestScheduledExecutor_periodic_panic(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	var ran int32
+	task, _ := executor.ScheduleAtFixedRate(time.Second, time.Second, func() {
+		if atomic.AddInt32(&ran, 1) == 2 {
+			panic("boom")
+		}
+	})
+	clock.Advance(time.Second)
+	waitFor(t, func() bool {
+		return executor.Len() == 1
+	})
+	clock.Advance(time.Second)
+	<-task.Done()
+	var panicErr *PanicError
+	assert.ErrorAs(t, task.Err(), &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Equal(t, 0, executor.Len())
+}
+
+func TestScheduledExecutor_Stop(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+
+	task, _ := executor.ScheduleAtFixedRate(time.Second, time.Second, func() {})
+	executor.Stop()
+	<-task.Done()
+	assert.Equal(t, ErrCancelled, task.Err())
+	_, err := executor.Schedule(time.Second, func() {})
+	assert.Equal(t, ErrSchedulerStopped, err)
+}
+
+func TestScheduledExecutor_pool_stopped(t *testing.T) {
+	pool := NewRoutinePool(1)
+	clock := newFakeClock()
+	executor := NewScheduledExecutor(pool, clock)
+	defer executor.Stop()
+
+	task, _ := executor.Schedule(time.Second, func() {})
+	pool.Stop()
+	clock.Advance(time.Second)
+	<-task.Done()
+	assert.Equal(t, ErrPoolStopped, task.Err())
+}
+
+func TestScheduledExecutor_system_clock(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	executor := NewScheduledExecutor(pool, nil)
+	defer executor.Stop()
+
+	start := time.Now()
+	task, _ := executor.Schedule(20*time.Millisecond, func() {})
+	<-task.Done()
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+// racyClock 定时器的 Stop 总是失败, 模拟停止时定时器已经触发的竞争, 由测试手动触发
+type racyClock struct {
+	mu     sync.Mutex
+	timers []func()
+}
+
+type racyTimer struct{}
+
+func (racyTimer) Stop() bool {
+	return false
+}
+
+func (c *racyClock) Now() time.Time {
+	return time.Unix(0, 0)
+}
+
+func (c *racyClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timers = append(c.timers, f)
+	return racyTimer{}
+}
+
+func (c *racyClock) created() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func TestScheduledExecutor_stale_timer_ignored(t *testing.T) {
+	pool := NewRoutinePool(1)
+	defer pool.Stop()
+	clock := &racyClock{}
+	e := NewScheduledExecutor(pool, clock)
+	defer e.Stop()
+
+	_, _ = e.Schedule(10*time.Second, func() {})
+	_, _ = e.Schedule(5*time.Second, func() {})
+	assert.Equal(t, 2, clock.created())
+
+	// 停止失败的旧定时器触发时不能替换当前的定时器
+	clock.timers[0]()
+	assert.Equal(t, 2, clock.created())
+	assert.Equal(t, 2, e.Len())
+}
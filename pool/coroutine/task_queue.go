@@ -0,0 +1,147 @@
+package coroutine
+
+import (
+	"github.com/koleter/go-util/list/linkedlist"
+	"sort"
+)
+
+// task 队列中的任务
+type task struct {
+	run      func()
+	reject   func(err error) // 任务入队后被丢弃时调用, 可以为nil
+	dropped  func()          // 任务被 ShutdownNow 移出队列时调用, 可以为nil
+	captured bool            // run已经捕获了提交者的 g.InheritableThreadLocal 的值, 提交时不再捕获
+	priority int
+	class    string
+	seq      uint64 // 入队序号
+}
+
+// taskQueue 任务队列, 优先级高的任务先出队, 同一优先级内各任务类别按权重公平出队, 同一类别内FIFO
+type taskQueue struct {
+	weights    map[string]int
+	levels     map[int]*priorityLevel
+	priorities []int // 有任务的优先级, 降序
+	size       int
+	seq        uint64
+}
+
+// priorityLevel 同一优先级的任务, 按 stride scheduling 在任务类别间分配执行机会:
+// 每个类别出队一个任务后pass增加1/weight, 每次选择pass最小的类别
+type priorityLevel struct {
+	classes map[string]*taskClass
+	active  []*taskClass // 有任务的类别, 按加入顺序
+	vtime   float64      // 最近一次出队的类别的pass, 新加入的类别从这里开始, 不能用空闲期间积累的份额插队
+}
+
+type taskClass struct {
+	name   string
+	stride float64
+	tasks  linkedlist.LinkedList[*task]
+	pass   float64
+}
+
+func newTaskQueue(weights map[string]int) taskQueue {
+	return taskQueue{
+		weights: weights,
+		levels:  map[int]*priorityLevel{},
+	}
+}
+
+func (q *taskQueue) Len() int {
+	return q.size
+}
+
+// Append 将任务加入队列
+func (q *taskQueue) Append(t *task) {
+	q.seq++
+	t.seq = q.seq
+	level, ok := q.levels[t.priority]
+	if !ok {
+		level = &priorityLevel{classes: map[string]*taskClass{}}
+		q.levels[t.priority] = level
+		i := sort.Search(len(q.priorities), func(i int) bool {
+			return q.priorities[i] < t.priority
+		})
+		q.priorities = append(q.priorities, 0)
+		copy(q.priorities[i+1:], q.priorities[i:])
+		q.priorities[i] = t.priority
+	}
+	class, ok := level.classes[t.class]
+	if !ok {
+		weight := q.weights[t.class]
+		if weight <= 0 {
+			weight = 1
+		}
+		class = &taskClass{name: t.class, stride: 1 / float64(weight)}
+		level.classes[t.class] = class
+	}
+	if class.tasks.Len() == 0 {
+		if class.pass < level.vtime {
+			class.pass = level.vtime
+		}
+		level.active = append(level.active, class)
+	}
+	class.tasks.Append(t)
+	q.size++
+}
+
+// Pop 取出优先级最高的任务
+func (q *taskQueue) Pop() (*task, bool) {
+	if q.size == 0 {
+		return nil, false
+	}
+	priority := q.priorities[0]
+	level := q.levels[priority]
+	idx := 0
+	for i, class := range level.active {
+		if class.pass < level.active[idx].pass {
+			idx = i
+		}
+	}
+	class := level.active[idx]
+	t, _ := class.tasks.Pop()
+	level.vtime = class.pass
+	class.pass += class.stride
+	q.remove(priority, level, idx)
+	return t, true
+}
+
+// PopOldest 取出优先级最低的任务中最早入队的任务
+func (q *taskQueue) PopOldest() (*task, bool) {
+	if q.size == 0 {
+		return nil, false
+	}
+	priority := q.priorities[len(q.priorities)-1]
+	level := q.levels[priority]
+	idx := 0
+	for i, class := range level.active {
+		if class.head().seq < level.active[idx].head().seq {
+			idx = i
+		}
+	}
+	t, _ := level.active[idx].tasks.Pop()
+	q.remove(priority, level, idx)
+	return t, true
+}
+
+// remove 出队后移除空的类别与优先级
+func (q *taskQueue) remove(priority int, level *priorityLevel, idx int) {
+	q.size--
+	if level.active[idx].tasks.Len() > 0 {
+		return
+	}
+	level.active = append(level.active[:idx], level.active[idx+1:]...)
+	if len(level.active) > 0 {
+		return
+	}
+	delete(q.levels, priority)
+	i := sort.Search(len(q.priorities), func(i int) bool {
+		return q.priorities[i] <= priority
+	})
+	q.priorities = append(q.priorities[:i], q.priorities[i+1:]...)
+}
+
+func (c *taskClass) head() *task {
+	t, _ := c.tasks.Peek()
+	return t
+}
@@ -0,0 +1,136 @@
+package coroutine
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func popNames(q *taskQueue, n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		t, ok := q.Pop()
+		if !ok {
+			break
+		}
+		names = append(names, t.class)
+	}
+	return names
+}
+
+func TestTaskQueue_priority(t *testing.T) {
+	q := newTaskQueue(nil)
+	for _, priority := range []int{0, 5, -1, 5, 3} {
+		q.Append(&task{priority: priority})
+	}
+	assert.Equal(t, 5, q.Len())
+	var priorities []int
+	for task, ok := q.Pop(); ok; task, ok = q.Pop() {
+		priorities = append(priorities, task.priority)
+	}
+	assert.Equal(t, []int{5, 5, 3, 0, -1}, priorities)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestTaskQueue_fifo_within_class(t *testing.T) {
+	q := newTaskQueue(nil)
+	for i := 0; i < 3; i++ {
+		q.Append(&task{class: "a"})
+	}
+	var seqs []uint64
+	for task, ok := q.Pop(); ok; task, ok = q.Pop() {
+		seqs = append(seqs, task.seq)
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, seqs)
+}
+
+func TestTaskQueue_weighted_fair(t *testing.T) {
+	q := newTaskQueue(map[string]int{"interactive": 3})
+	for i := 0; i < 100; i++ {
+		q.Append(&task{class: "batch"})
+	}
+	for i := 0; i < 30; i++ {
+		q.Append(&task{class: "interactive"})
+	}
+	counts := map[string]int{}
+	for _, name := range popNames(&q, 40) {
+		counts[name]++
+	}
+	assert.Equal(t, 30, counts["interactive"])
+	assert.Equal(t, 10, counts["batch"])
+}
+
+func TestTaskQueue_idle_class_does_not_accumulate_share(t *testing.T) {
+	q := newTaskQueue(nil)
+	q.Append(&task{class: "a"})
+	q.Append(&task{class: "b"})
+	popNames(&q, 1)
+	for i := 0; i < 10; i++ {
+		q.Append(&task{class: "b"})
+	}
+	popNames(&q, 5)
+	// a在b执行期间空闲, 重新加入后与b交替执行而不是连续执行
+	for i := 0; i < 4; i++ {
+		q.Append(&task{class: "a"})
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, popNames(&q, 4))
+}
+
+func TestTaskQueue_PopOldest(t *testing.T) {
+	q := newTaskQueue(nil)
+	q.Append(&task{priority: 1, class: "a"})
+	q.Append(&task{priority: 0, class: "b"})
+	q.Append(&task{priority: 0, class: "a"})
+	oldest, ok := q.PopOldest()
+	assert.True(t, ok)
+	assert.Equal(t, 0, oldest.priority)
+	assert.Equal(t, "b", oldest.class)
+	assert.Equal(t, 2, q.Len())
+	task, _ := q.Pop()
+	assert.Equal(t, 1, task.priority)
+}
+
+func TestRoutinePool_SubmitPriority(t *testing.T) {
+	pool, block := blockPool(t)
+	var mu sync.Mutex
+	var order []int
+	for _, priority := range []int{1, 3, 2} {
+		priority := priority
+		assert.NoError(t, pool.SubmitPriority(priority, func() {
+			mu.Lock()
+			order = append(order, priority)
+			mu.Unlock()
+		}))
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, []int{3, 2, 1}, order)
+}
+
+func TestRoutinePool_SubmitClass(t *testing.T) {
+	pool, block := blockPool(t, WithClassWeight("interactive", 2))
+	var mu sync.Mutex
+	var order []string
+	submit := func(class string) {
+		assert.NoError(t, pool.SubmitClass(class, 0, func() {
+			mu.Lock()
+			order = append(order, class)
+			mu.Unlock()
+		}))
+	}
+	for i := 0; i < 6; i++ {
+		submit("batch")
+	}
+	for i := 0; i < 4; i++ {
+		submit("interactive")
+	}
+	close(block)
+	pool.Stop()
+	pool.Wait()
+	assert.Equal(t, []string{
+		"batch", "interactive", "interactive", "batch", "interactive", "interactive",
+		"batch", "batch", "batch", "batch",
+	}, order)
+}
@@ -0,0 +1,418 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed 资源池已关闭
+	ErrPoolClosed = errors.New("resource pool is closed")
+)
+
+// Factory 资源的创建、销毁与校验
+type Factory[T any] struct {
+	// Create 创建资源, 不能为nil; ctx为借出时传入的ctx, 补充最小空闲资源时为 context.Background
+	Create func(ctx context.Context) (T, error)
+	// Destroy 销毁资源, 为nil时不做任何操作
+	Destroy func(T)
+	// Validate 校验资源是否可用, 返回错误时资源被销毁; 为nil时不校验
+	Validate func(T) error
+}
+
+// Option 资源池的配置项
+type Option func(*options)
+
+type options struct {
+	maxTotal         int
+	maxIdle          int
+	minIdle          int
+	idleTimeout      time.Duration
+	evictionInterval time.Duration
+	testOnBorrow     bool
+	testOnReturn     bool
+}
+
+const (
+	defaultMaxTotal    = 8
+	defaultMaxIdle     = 8
+	defaultIdleTimeout = 30 * time.Minute
+)
+
+// WithMaxTotal 设置资源的最大数量, 包括空闲的与借出的资源, 默认为8, 小于等于0时不限制
+func WithMaxTotal(maxTotal int) Option {
+	return func(o *options) {
+		o.maxTotal = maxTotal
+	}
+}
+
+// WithMaxIdle 设置空闲资源的最大数量, 默认为8; 归还时空闲资源已达到最大数量则销毁归还的资源
+func WithMaxIdle(maxIdle int) Option {
+	return func(o *options) {
+		o.maxIdle = maxIdle
+	}
+}
+
+// WithMinIdle 设置空闲资源的最小数量, 默认为0; 后台驱逐时不会驱逐到少于这个数量, 并且会创建资源补足这个数量
+func WithMinIdle(minIdle int) Option {
+	return func(o *options) {
+		o.minIdle = minIdle
+	}
+}
+
+// WithIdleTimeout 设置资源空闲多久后可以被驱逐, 默认为30分钟
+func WithIdleTimeout(idleTimeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = idleTimeout
+	}
+}
+
+// WithEvictionInterval 设置后台驱逐空闲资源的间隔, 默认为0, 即不启动后台驱逐
+func WithEvictionInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.evictionInterval = interval
+	}
+}
+
+// WithTestOnBorrow 借出空闲资源前使用 Factory.Validate 校验, 校验失败的资源被销毁并继续借出其他资源
+func WithTestOnBorrow() Option {
+	return func(o *options) {
+		o.testOnBorrow = true
+	}
+}
+
+// WithTestOnReturn 归还资源时使用 Factory.Validate 校验, 校验失败的资源被销毁
+func WithTestOnReturn() Option {
+	return func(o *options) {
+		o.testOnReturn = true
+	}
+}
+
+// Stats 资源池的运行统计
+type Stats struct {
+	MaxTotal         int           // 资源的最大数量
+	Total            int           // 当前资源数量, 包括空闲的、借出的与正在创建的资源
+	Idle             int           // 空闲资源数量
+	Active           int           // 借出的与正在创建的资源数量
+	Waiting          int           // 等待借出的协程数量
+	Created          uint64        // 累计创建的资源数量
+	Destroyed        uint64        // 累计销毁的资源数量
+	Borrowed         uint64        // 累计借出的次数
+	Returned         uint64        // 累计归还的次数
+	Evicted          uint64        // 累计因空闲超时被驱逐的资源数量
+	ValidationFailed uint64        // 累计校验失败的次数
+	WaitCount        uint64        // 累计需要等待才能借出的次数
+	WaitDuration     time.Duration // 累计等待借出的时间
+}
+
+type idleResource[T any] struct {
+	res   T
+	since time.Time
+}
+
+// Pool 数量有上限的资源池, 用于连接等创建代价高或持有外部资源的对象.
+// 与 sync.Pool 不同, 空闲资源不会被GC回收, 只会在空闲超时后被后台驱逐或在关闭时销毁
+type Pool[T any] struct {
+	factory Factory[T]
+	opts    options
+
+	mu      sync.Mutex
+	idle    []idleResource[T] // 按归还时间从早到晚排列, 借出时取最近归还的资源
+	total   int
+	waiters []chan struct{} // 按开始等待的顺序排列
+	closed  bool
+	stats   Stats
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPool 创建一个新的实例, 设置了驱逐间隔时启动后台驱逐的协程, 使用完后需要调用 Close
+func NewPool[T any](factory Factory[T], opts ...Option) *Pool[T] {
+	if factory.Create == nil {
+		panic("resource pool factory Create is nil")
+	}
+	o := options{
+		maxTotal:    defaultMaxTotal,
+		maxIdle:     defaultMaxIdle,
+		idleTimeout: defaultIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.minIdle > o.maxIdle {
+		panic(fmt.Sprintf("minIdle is greater than maxIdle, minIdle: %d, maxIdle: %d", o.minIdle, o.maxIdle))
+	}
+	if (o.testOnBorrow || o.testOnReturn) && factory.Validate == nil {
+		panic("resource pool factory Validate is nil")
+	}
+	p := &Pool[T]{
+		factory: factory,
+		opts:    o,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if o.evictionInterval > 0 {
+		go p.evictLoop()
+	} else {
+		close(p.done)
+	}
+	return p
+}
+
+// Borrow 借出资源, 有空闲资源时借出最近归还的资源, 否则在未达到最大数量时创建新的资源,
+// 已达到最大数量时等待其他资源归还或销毁, 直到ctx结束并返回ctx.Err()
+func (p *Pool[T]) Borrow(ctx context.Context) (T, error) {
+	var zero T
+	var waitStart time.Time
+	notified := false
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return zero, ErrPoolClosed
+		}
+		if n := len(p.idle); n > 0 {
+			res := p.idle[n-1].res
+			p.idle[n-1] = idleResource[T]{}
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			if p.opts.testOnBorrow && p.factory.Validate(res) != nil {
+				p.invalidate(res, true)
+				continue
+			}
+			p.borrowed(waitStart)
+			return res, nil
+		}
+		if p.opts.maxTotal <= 0 || p.total < p.opts.maxTotal {
+			p.total++
+			p.mu.Unlock()
+			res, err := p.create(ctx)
+			if err != nil {
+				return zero, err
+			}
+			p.borrowed(waitStart)
+			return res, nil
+		}
+		ch := make(chan struct{}, 1)
+		if notified {
+			// 被通知后资源又被其他协程抢先借出, 回到队首保持等待顺序, 避免在高负载下被饿死
+			p.waiters = append([]chan struct{}{ch}, p.waiters...)
+		} else {
+			p.waiters = append(p.waiters, ch)
+		}
+		p.mu.Unlock()
+
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+		}
+		select {
+		case <-ch:
+			notified = true
+		case <-ctx.Done():
+			p.mu.Lock()
+			if !p.removeWaiter(ch) {
+				// 已经被通知, 将通知转交给下一个等待者
+				p.notifyLocked()
+			}
+			p.mu.Unlock()
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Return 归还借出的资源, 资源池已关闭、校验失败或空闲资源已达到最大数量时销毁资源.
+// 每个借出的资源只能归还或 Invalidate 一次
+func (p *Pool[T]) Return(res T) {
+	valid := !p.opts.testOnReturn || p.factory.Validate(res) == nil
+	p.mu.Lock()
+	p.stats.Returned++
+	if !valid || p.closed || len(p.idle) >= p.opts.maxIdle {
+		p.mu.Unlock()
+		p.invalidate(res, !valid)
+		return
+	}
+	p.idle = append(p.idle, idleResource[T]{res: res, since: time.Now()})
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// Invalidate 销毁借出的资源而不是归还, 用于使用时发现资源已经不可用的情况
+func (p *Pool[T]) Invalidate(res T) {
+	p.invalidate(res, false)
+}
+
+// Close 关闭资源池, 停止后台驱逐并销毁所有空闲资源, 等待中的 Borrow 返回 ErrPoolClosed;
+// 之后归还的资源会被直接销毁
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	idle := p.idle
+	p.idle = nil
+	p.total -= len(idle)
+	p.stats.Destroyed += uint64(len(idle))
+	for len(p.waiters) > 0 {
+		p.notifyLocked()
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	for _, r := range idle {
+		p.destroy(r.res)
+	}
+}
+
+// Stats 返回运行统计
+func (p *Pool[T]) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.MaxTotal = p.opts.maxTotal
+	stats.Total = p.total
+	stats.Idle = len(p.idle)
+	stats.Active = p.total - len(p.idle)
+	stats.Waiting = len(p.waiters)
+	return stats
+}
+
+// create 创建资源, 调用前需要已经将total加1, 创建失败时减回
+func (p *Pool[T]) create(ctx context.Context) (T, error) {
+	res, err := p.factory.Create(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.total--
+		p.notifyLocked()
+		return res, err
+	}
+	p.stats.Created++
+	return res, nil
+}
+
+func (p *Pool[T]) borrowed(waitStart time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Borrowed++
+	if !waitStart.IsZero() {
+		p.stats.WaitCount++
+		p.stats.WaitDuration += time.Since(waitStart)
+	}
+}
+
+// invalidate 销毁不在空闲列表中的资源, 并通知等待者可以创建新的资源
+func (p *Pool[T]) invalidate(res T, validationFailed bool) {
+	p.mu.Lock()
+	p.total--
+	p.stats.Destroyed++
+	if validationFailed {
+		p.stats.ValidationFailed++
+	}
+	p.notifyLocked()
+	p.mu.Unlock()
+	p.destroy(res)
+}
+
+func (p *Pool[T]) destroy(res T) {
+	if p.factory.Destroy != nil {
+		p.factory.Destroy(res)
+	}
+}
+
+// notifyLocked 通知最早开始等待的等待者重新尝试借出
+func (p *Pool[T]) notifyLocked() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	ch := p.waiters[0]
+	p.waiters[0] = nil
+	p.waiters = p.waiters[1:]
+	ch <- struct{}{}
+}
+
+// removeWaiter 移除等待者, 等待者已经被通知时返回false
+func (p *Pool[T]) removeWaiter(ch chan struct{}) bool {
+	for i, w := range p.waiters {
+		if w == ch {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool[T]) evictLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.evictionInterval)
+	defer ticker.Stop()
+	p.ensureMinIdle()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evict()
+			p.ensureMinIdle()
+		}
+	}
+}
+
+// evict 销毁空闲超时的资源, 保留至少 minIdle 个空闲资源
+func (p *Pool[T]) evict() {
+	now := time.Now()
+	p.mu.Lock()
+	n := 0
+	for n < len(p.idle)-p.opts.minIdle && now.Sub(p.idle[n].since) >= p.opts.idleTimeout {
+		n++
+	}
+	evicted := make([]T, n)
+	for i := 0; i < n; i++ {
+		evicted[i] = p.idle[i].res
+	}
+	p.idle = append(p.idle[:0], p.idle[n:]...)
+	p.total -= n
+	p.stats.Destroyed += uint64(n)
+	p.stats.Evicted += uint64(n)
+	p.mu.Unlock()
+
+	for _, res := range evicted {
+		p.destroy(res)
+	}
+}
+
+// ensureMinIdle 创建资源直到空闲资源达到 minIdle 个, 创建失败时等待下一次驱逐再补充
+func (p *Pool[T]) ensureMinIdle() {
+	for {
+		p.mu.Lock()
+		if p.closed || len(p.idle) >= p.opts.minIdle || (p.opts.maxTotal > 0 && p.total >= p.opts.maxTotal) {
+			p.mu.Unlock()
+			return
+		}
+		p.total++
+		p.mu.Unlock()
+
+		res, err := p.create(context.Background())
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.total--
+			p.stats.Destroyed++
+			p.mu.Unlock()
+			p.destroy(res)
+			return
+		}
+		// 新创建的资源视为最近归还, 放在最后
+		p.idle = append(p.idle, idleResource[T]{res: res, since: time.Now()})
+		p.notifyLocked()
+		p.mu.Unlock()
+	}
+}
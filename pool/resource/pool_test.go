@@ -0,0 +1,364 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type conn struct {
+	id     int
+	broken bool
+	closed bool
+}
+
+type connFactory struct {
+	mu        sync.Mutex
+	nextID    int
+	destroyed []int
+	createErr error
+}
+
+func (f *connFactory) factory() Factory[*conn] {
+	return Factory[*conn]{
+		Create: func(ctx context.Context) (*conn, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.createErr != nil {
+				return nil, f.createErr
+			}
+			f.nextID++
+			return &conn{id: f.nextID}, nil
+		},
+		Destroy: func(c *conn) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			c.closed = true
+			f.destroyed = append(f.destroyed, c.id)
+		},
+		Validate: func(c *conn) error {
+			if c.broken {
+				return errors.New("broken")
+			}
+			return nil
+		},
+	}
+}
+
+func (f *connFactory) destroyedIDs() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.destroyed...)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not satisfied in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_Borrow_reuse(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory())
+	defer p.Close()
+
+	c1, err := p.Borrow(context.Background())
+	assert.NoError(t, err)
+	c2, _ := p.Borrow(context.Background())
+	assert.Equal(t, 1, c1.id)
+	assert.Equal(t, 2, c2.id)
+
+	p.Return(c1)
+	p.Return(c2)
+	// 借出最近归还的资源
+	c, _ := p.Borrow(context.Background())
+	assert.Same(t, c2, c)
+
+	stats := p.Stats()
+	assert.Equal(t, 2, stats.Total)
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, 1, stats.Active)
+	assert.Equal(t, uint64(2), stats.Created)
+	assert.Equal(t, uint64(3), stats.Borrowed)
+	assert.Equal(t, uint64(2), stats.Returned)
+}
+
+func TestPool_Borrow_create_error(t *testing.T) {
+	f := &connFactory{createErr: errors.New("refused")}
+	p := NewPool(f.factory(), WithMaxTotal(1))
+	defer p.Close()
+
+	_, err := p.Borrow(context.Background())
+	assert.EqualError(t, err, "refused")
+	// 创建失败不占用数量
+	assert.Equal(t, 0, p.Stats().Total)
+}
+
+func TestPool_Borrow_timeout(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(1))
+	defer p.Close()
+
+	c, _ := p.Borrow(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := p.Borrow(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, p.Stats().Waiting)
+
+	p.Return(c)
+	got, err := p.Borrow(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, c, got)
+}
+
+func TestPool_Borrow_wait_for_return(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(1))
+	defer p.Close()
+
+	c, _ := p.Borrow(context.Background())
+	result := make(chan *conn)
+	go func() {
+		got, _ := p.Borrow(context.Background())
+		result <- got
+	}()
+	waitFor(t, func() bool { return p.Stats().Waiting == 1 })
+	time.Sleep(5 * time.Millisecond)
+	p.Return(c)
+	assert.Same(t, c, <-result)
+
+	stats := p.Stats()
+	assert.Equal(t, uint64(1), stats.WaitCount)
+	assert.GreaterOrEqual(t, stats.WaitDuration, 5*time.Millisecond)
+}
+
+func TestPool_Borrow_wait_for_invalidate(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(1))
+	defer p.Close()
+
+	c, _ := p.Borrow(context.Background())
+	result := make(chan *conn)
+	go func() {
+		got, _ := p.Borrow(context.Background())
+		result <- got
+	}()
+	waitFor(t, func() bool { return p.Stats().Waiting == 1 })
+	p.Invalidate(c)
+	// 销毁资源后等待者创建新的资源
+	assert.Equal(t, 2, (<-result).id)
+	assert.True(t, c.closed)
+}
+
+func TestPool_max_total_concurrent(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(3))
+	defer p.Close()
+
+	var active, maxActive int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				c, err := p.Borrow(context.Background())
+				if !assert.NoError(t, err) {
+					return
+				}
+				n := atomic.AddInt32(&active, 1)
+				for {
+					m := atomic.LoadInt32(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Microsecond)
+				atomic.AddInt32(&active, -1)
+				p.Return(c)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(3))
+	assert.Equal(t, uint64(3), p.Stats().Created)
+	assert.Equal(t, uint64(400), p.Stats().Borrowed)
+}
+
+func TestPool_Return_max_idle(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxIdle(1))
+	defer p.Close()
+
+	c1, _ := p.Borrow(context.Background())
+	c2, _ := p.Borrow(context.Background())
+	p.Return(c1)
+	p.Return(c2)
+	assert.Equal(t, []int{2}, f.destroyedIDs())
+	assert.Equal(t, 1, p.Stats().Total)
+}
+
+func TestPool_TestOnBorrow(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithTestOnBorrow())
+	defer p.Close()
+
+	c1, _ := p.Borrow(context.Background())
+	c2, _ := p.Borrow(context.Background())
+	p.Return(c1)
+	p.Return(c2)
+	c2.broken = true
+
+	c, err := p.Borrow(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, c1, c)
+	assert.True(t, c2.closed)
+	assert.Equal(t, uint64(1), p.Stats().ValidationFailed)
+}
+
+func TestPool_TestOnReturn(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithTestOnReturn())
+	defer p.Close()
+
+	c, _ := p.Borrow(context.Background())
+	c.broken = true
+	p.Return(c)
+	assert.True(t, c.closed)
+
+	stats := p.Stats()
+	assert.Equal(t, 0, stats.Total)
+	assert.Equal(t, uint64(1), stats.Returned)
+	assert.Equal(t, uint64(1), stats.ValidationFailed)
+	assert.Equal(t, uint64(1), stats.Destroyed)
+}
+
+func TestNewPool_Validate_required(t *testing.T) {
+	f := &connFactory{}
+	factory := f.factory()
+	factory.Validate = nil
+	assert.Panics(t, func() { NewPool(factory, WithTestOnBorrow()) })
+	assert.Panics(t, func() { NewPool(Factory[*conn]{}) })
+	assert.Panics(t, func() { NewPool(f.factory(), WithMinIdle(2), WithMaxIdle(1)) })
+}
+
+func TestPool_evict(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(),
+		WithMinIdle(1),
+		WithIdleTimeout(20*time.Millisecond),
+		WithEvictionInterval(5*time.Millisecond),
+	)
+	defer p.Close()
+
+	// 后台驱逐补足最小空闲资源
+	waitFor(t, func() bool { return p.Stats().Idle == 1 })
+
+	var conns []*conn
+	for i := 0; i < 3; i++ {
+		c, _ := p.Borrow(context.Background())
+		conns = append(conns, c)
+	}
+	for _, c := range conns {
+		p.Return(c)
+	}
+	assert.Equal(t, 3, p.Stats().Idle)
+
+	// 空闲超时后驱逐到只剩最小空闲数量, 保留最近归还的资源
+	waitFor(t, func() bool { return p.Stats().Idle == 1 })
+	assert.Equal(t, uint64(2), p.Stats().Evicted)
+	c, _ := p.Borrow(context.Background())
+	assert.Same(t, conns[2], c)
+}
+
+func TestPool_Close(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(2), WithEvictionInterval(time.Millisecond))
+
+	c1, _ := p.Borrow(context.Background())
+	c2, _ := p.Borrow(context.Background())
+	p.Return(c1)
+	c3, _ := p.Borrow(context.Background())
+	p.Return(c3)
+
+	errs := make(chan error)
+	c4, _ := p.Borrow(context.Background())
+	go func() {
+		_, err := p.Borrow(context.Background())
+		errs <- err
+	}()
+	waitFor(t, func() bool { return p.Stats().Waiting == 1 })
+
+	p.Close()
+	assert.ErrorIs(t, <-errs, ErrPoolClosed)
+	_, err := p.Borrow(context.Background())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+
+	// 关闭后归还的资源被直接销毁
+	p.Return(c2)
+	p.Return(c4)
+	assert.True(t, c2.closed)
+	assert.True(t, c4.closed)
+	assert.Equal(t, 0, p.Stats().Total)
+	p.Close()
+}
+
+func TestPool_Close_destroys_idle(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory())
+	c1, _ := p.Borrow(context.Background())
+	c2, _ := p.Borrow(context.Background())
+	p.Return(c1)
+	p.Return(c2)
+
+	p.Close()
+	assert.ElementsMatch(t, []int{1, 2}, f.destroyedIDs())
+	stats := p.Stats()
+	assert.Equal(t, 0, stats.Total)
+	assert.Equal(t, uint64(2), stats.Destroyed)
+}
+
+func TestPool_Borrow_notified_waiter_keeps_position(t *testing.T) {
+	f := &connFactory{}
+	p := NewPool(f.factory(), WithMaxTotal(1))
+	defer p.Close()
+
+	c, _ := p.Borrow(context.Background())
+	first, second := make(chan *conn), make(chan *conn)
+	go func() {
+		got, _ := p.Borrow(context.Background())
+		first <- got
+	}()
+	waitFor(t, func() bool { return p.Stats().Waiting == 1 })
+	go func() {
+		got, _ := p.Borrow(context.Background())
+		second <- got
+	}()
+	waitFor(t, func() bool { return p.Stats().Waiting == 2 })
+
+	// 归还后队首的等待者被通知, 但资源在它醒来之前被其他协程抢先借出
+	p.mu.Lock()
+	p.notifyLocked()
+	p.mu.Unlock()
+	waitFor(t, func() bool { return p.Stats().Waiting == 2 })
+
+	p.Return(c)
+	select {
+	case got := <-first:
+		assert.Same(t, c, got)
+	case <-second:
+		t.Fatal("notified waiter lost its position")
+	}
+	p.Return(c)
+	assert.Same(t, c, <-second)
+}
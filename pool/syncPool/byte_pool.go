@@ -0,0 +1,315 @@
+package syncPool
+
+import (
+	"io"
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	minClassBits = 6  // 最小的大小等级为64字节
+	maxClassBits = 25 // 最大的大小等级为32MB
+	numClasses   = maxClassBits - minClassBits + 1
+
+	// calibrateCalls 每归还这么多次缓冲区后根据使用的大小重新计算默认大小与最大大小
+	calibrateCalls = 42000
+	// maxPercentile 最大大小覆盖的归还次数的比例, 更大的缓冲区不再放回池中
+	maxPercentile = 0.95
+)
+
+// BytePool 按2的幂划分大小等级的字节缓冲区池, 每个等级使用一个 sync.Pool.
+//
+// 与 bytebufferpool 相同, 会统计归还的缓冲区的使用大小, 周期性地计算出最常用的大小作为默认大小,
+// 并丢弃超过绝大多数使用大小的缓冲区, 避免偶尔出现的大缓冲区长期占用内存
+type BytePool struct {
+	classes [numClasses]sync.Pool // 元素为*[]byte, 等级i中的缓冲区容量不小于 1<<(i+minClassBits)
+	holders sync.Pool             // 复用*[]byte, 避免归还时分配内存
+	buffers sync.Pool             // *ByteBuffer
+
+	calls       [numClasses]uint64
+	calibrating uint32
+	defaultSize uint64
+	maxSize     uint64
+}
+
+// NewBytePool 创建一个新的实例
+func NewBytePool() *BytePool {
+	return &BytePool{}
+}
+
+var defaultBytePool BytePool
+
+// GetBytes 从默认的池中获取容量不小于minCap的缓冲区, 见 BytePool.Get
+func GetBytes(minCap int) []byte {
+	return defaultBytePool.Get(minCap)
+}
+
+// PutBytes 将缓冲区归还到默认的池中, 见 BytePool.Put
+func PutBytes(buf []byte) {
+	defaultBytePool.Put(buf)
+}
+
+// GetBuffer 从默认的池中获取一个 ByteBuffer
+func GetBuffer() *ByteBuffer {
+	return defaultBytePool.GetBuffer()
+}
+
+// PutBuffer 将 ByteBuffer 归还到默认的池中
+func PutBuffer(b *ByteBuffer) {
+	defaultBytePool.PutBuffer(b)
+}
+
+// Get 获取长度为0, 容量不小于minCap的缓冲区, minCap小于等于0时使用统计得到的默认大小;
+// minCap超过最大的大小等级时直接分配
+func (p *BytePool) Get(minCap int) []byte {
+	if minCap <= 0 {
+		minCap = int(atomic.LoadUint64(&p.defaultSize))
+	}
+	i := classOf(minCap)
+	if i >= numClasses {
+		return make([]byte, 0, minCap)
+	}
+	if v := p.classes[i].Get(); v != nil {
+		h := v.(*[]byte)
+		buf := *h
+		*h = nil
+		p.holders.Put(h)
+		return buf[:0]
+	}
+	return make([]byte, 0, 1<<(i+minClassBits))
+}
+
+// Put 归还缓冲区, 缓冲区按长度计入统计, 容量小于最小等级或超过统计得到的最大大小时丢弃.
+// 归还后不能再使用buf
+func (p *BytePool) Put(buf []byte) {
+	p.record(len(buf))
+	p.put(buf)
+}
+
+// put 归还缓冲区但不计入统计, 用于扩容时归还的中间缓冲区
+func (p *BytePool) put(buf []byte) {
+	c := cap(buf)
+	if c < 1<<minClassBits {
+		return
+	}
+	if maxSize := atomic.LoadUint64(&p.maxSize); maxSize != 0 && uint64(c) > maxSize {
+		return
+	}
+	// 按容量向下取等级, 保证等级i中的缓冲区容量不小于该等级的大小
+	i := bits.Len(uint(c)) - 1 - minClassBits
+	if i >= numClasses {
+		return
+	}
+	h, _ := p.holders.Get().(*[]byte)
+	if h == nil {
+		h = new([]byte)
+	}
+	*h = buf[:0]
+	p.classes[i].Put(h)
+}
+
+// GetBuffer 获取一个 ByteBuffer, 初始容量为统计得到的默认大小
+func (p *BytePool) GetBuffer() *ByteBuffer {
+	b, _ := p.buffers.Get().(*ByteBuffer)
+	if b == nil {
+		b = &ByteBuffer{pool: p}
+	}
+	if b.B == nil {
+		b.B = p.Get(0)
+	}
+	return b
+}
+
+// PutBuffer 归还 ByteBuffer, 归还后不能再使用b
+func (p *BytePool) PutBuffer(b *ByteBuffer) {
+	p.Put(b.B)
+	b.B = nil
+	b.pool = p
+	p.buffers.Put(b)
+}
+
+// classOf 返回容量不小于size的最小等级, 超过最大等级时返回值不小于 numClasses
+func classOf(size int) int {
+	if size <= 1<<minClassBits {
+		return 0
+	}
+	return bits.Len(uint(size-1)) - minClassBits
+}
+
+// record 统计归还的缓冲区的使用大小, 次数达到阈值时重新计算默认大小与最大大小
+func (p *BytePool) record(n int) {
+	i := classOf(n)
+	if i >= numClasses {
+		i = numClasses - 1
+	}
+	if atomic.AddUint64(&p.calls[i], 1) > calibrateCalls {
+		p.calibrate()
+	}
+}
+
+func (p *BytePool) calibrate() {
+	if !atomic.CompareAndSwapUint32(&p.calibrating, 0, 1) {
+		return
+	}
+	type classCalls struct {
+		calls uint64
+		size  uint64
+	}
+	stats := make([]classCalls, 0, numClasses)
+	var total uint64
+	for i := range p.calls {
+		calls := atomic.SwapUint64(&p.calls[i], 0)
+		total += calls
+		stats = append(stats, classCalls{calls: calls, size: 1 << (i + minClassBits)})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].calls > stats[j].calls
+	})
+
+	defaultSize := stats[0].size
+	maxSize := defaultSize
+	maxSum := uint64(float64(total) * maxPercentile)
+	var sum uint64
+	for _, s := range stats {
+		if sum > maxSum {
+			break
+		}
+		sum += s.calls
+		if s.size > maxSize {
+			maxSize = s.size
+		}
+	}
+	// 最大大小所在等级中的缓冲区容量可能略大于等级的大小
+	maxSize = maxSize*2 - 1
+
+	atomic.StoreUint64(&p.defaultSize, defaultSize)
+	atomic.StoreUint64(&p.maxSize, maxSize)
+	atomic.StoreUint32(&p.calibrating, 0)
+}
+
+// ByteBuffer 从 BytePool 获取的可复用缓冲区, 提供与 bytes.Buffer 相同的写入方法, 扩容时从池中获取更大的缓冲区.
+// 零值可以直接使用, 此时从默认的池中扩容
+type ByteBuffer struct {
+	// B 缓冲区的内容, 可以直接使用 append 等方法修改
+	B    []byte
+	pool *BytePool
+}
+
+var (
+	_ io.Writer       = (*ByteBuffer)(nil)
+	_ io.StringWriter = (*ByteBuffer)(nil)
+	_ io.ByteWriter   = (*ByteBuffer)(nil)
+	_ io.ReaderFrom   = (*ByteBuffer)(nil)
+	_ io.WriterTo     = (*ByteBuffer)(nil)
+)
+
+// Len 缓冲区内容的长度
+func (b *ByteBuffer) Len() int {
+	return len(b.B)
+}
+
+// Cap 缓冲区的容量
+func (b *ByteBuffer) Cap() int {
+	return cap(b.B)
+}
+
+// Bytes 返回缓冲区的内容, 在下一次修改缓冲区之前有效
+func (b *ByteBuffer) Bytes() []byte {
+	return b.B
+}
+
+// String 以字符串返回缓冲区的内容
+func (b *ByteBuffer) String() string {
+	return string(b.B)
+}
+
+// Reset 清空缓冲区, 保留已分配的容量
+func (b *ByteBuffer) Reset() {
+	b.B = b.B[:0]
+}
+
+// Truncate 只保留前n个字节
+func (b *ByteBuffer) Truncate(n int) {
+	if n < 0 || n > len(b.B) {
+		panic("ByteBuffer: truncation out of range")
+	}
+	b.B = b.B[:n]
+}
+
+// Grow 保证缓冲区至少还能写入n个字节而不需要扩容
+func (b *ByteBuffer) Grow(n int) {
+	if n < 0 {
+		panic("ByteBuffer: negative count")
+	}
+	if cap(b.B)-len(b.B) >= n {
+		return
+	}
+	need := len(b.B) + n
+	if need < 2*cap(b.B) {
+		need = 2 * cap(b.B)
+	}
+	p := b.pool
+	if p == nil {
+		p = &defaultBytePool
+	}
+	buf := p.Get(need)
+	buf = append(buf, b.B...)
+	p.put(b.B)
+	b.B = buf
+}
+
+func (b *ByteBuffer) Write(p []byte) (int, error) {
+	b.Grow(len(p))
+	b.B = append(b.B, p...)
+	return len(p), nil
+}
+
+func (b *ByteBuffer) WriteString(s string) (int, error) {
+	b.Grow(len(s))
+	b.B = append(b.B, s...)
+	return len(s), nil
+}
+
+func (b *ByteBuffer) WriteByte(c byte) error {
+	b.Grow(1)
+	b.B = append(b.B, c)
+	return nil
+}
+
+func (b *ByteBuffer) WriteRune(r rune) (int, error) {
+	b.Grow(utf8.UTFMax)
+	n := len(b.B)
+	b.B = utf8.AppendRune(b.B, r)
+	return len(b.B) - n, nil
+}
+
+// ReadFrom 从r读取数据直到io.EOF并追加到缓冲区
+func (b *ByteBuffer) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		if cap(b.B)-len(b.B) < 512 {
+			b.Grow(512)
+		}
+		n, err := r.Read(b.B[len(b.B):cap(b.B)])
+		if n < 0 {
+			panic("ByteBuffer: reader returned negative count from Read")
+		}
+		b.B = b.B[:len(b.B)+n]
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// WriteTo 将缓冲区的内容写入w, 与 bytes.Buffer 不同, 写入后不会清空缓冲区
+func (b *ByteBuffer) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.B)
+	return int64(n), err
+}
@@ -0,0 +1,186 @@
+package syncPool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassOf(t *testing.T) {
+	assert.Equal(t, 0, classOf(0))
+	assert.Equal(t, 0, classOf(64))
+	assert.Equal(t, 1, classOf(65))
+	assert.Equal(t, 1, classOf(128))
+	assert.Equal(t, 4, classOf(1000))
+	assert.Equal(t, numClasses, classOf(1<<maxClassBits+1))
+}
+
+func TestBytePool_Get_capacity_is_power_of_two(t *testing.T) {
+	p := NewBytePool()
+	for _, n := range []int{0, 1, 64, 65, 1000, 4096, 5000} {
+		buf := p.Get(n)
+		assert.Equal(t, 0, len(buf))
+		assert.GreaterOrEqual(t, cap(buf), n)
+		assert.Equal(t, 0, cap(buf)&(cap(buf)-1), "cap %d of Get(%d)", cap(buf), n)
+	}
+	huge := p.Get(1<<maxClassBits + 1)
+	assert.Equal(t, 1<<maxClassBits+1, cap(huge))
+}
+
+// reused 归还buf后再次获取, 判断是否得到了同一个缓冲区.
+// 开启race检测时sync.Pool会随机丢弃对象, 因此重复多次
+func reused(p *BytePool, buf []byte, minCap int) bool {
+	for i := 0; i < 100; i++ {
+		p.Put(buf)
+		if got := p.Get(minCap); cap(got) == cap(buf) && &got[:1][0] == &buf[:1][0] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBytePool_Put_reuse_buffer(t *testing.T) {
+	p := NewBytePool()
+	buf := p.Get(100)
+	buf = append(buf, "hello"...)
+	assert.True(t, reused(p, buf, 128))
+}
+
+func TestBytePool_Put_floor_class(t *testing.T) {
+	p := NewBytePool()
+	// 容量为100的缓冲区只能放入64字节的等级
+	assert.True(t, reused(p, make([]byte, 0, 100), 64))
+	assert.Equal(t, 128, cap(p.Get(100)))
+}
+
+func TestBytePool_Put_drop_small_buffer(t *testing.T) {
+	p := NewBytePool()
+	p.Put(make([]byte, 0, 32))
+	assert.Equal(t, 64, cap(p.Get(1)))
+}
+
+func TestBytePool_calibrate(t *testing.T) {
+	p := NewBytePool()
+	for i := 0; i < calibrateCalls; i++ {
+		p.Put(make([]byte, 1000))
+	}
+	p.Put(make([]byte, 1000))
+	assert.Equal(t, uint64(1024), p.defaultSize)
+	assert.Equal(t, uint64(2047), p.maxSize)
+	assert.Equal(t, 1024, cap(p.Get(0)))
+
+	// 超过最大大小的缓冲区被丢弃
+	assert.False(t, reused(p, make([]byte, 0, 4096), 4096))
+	assert.True(t, reused(p, make([]byte, 0, 2000), 1024))
+}
+
+func TestBytePool_calibrate_percentile(t *testing.T) {
+	p := NewBytePool()
+	for i := 0; i < calibrateCalls; i++ {
+		if i%10 == 0 {
+			p.record(8000)
+		} else {
+			p.record(100)
+		}
+	}
+	p.calibrate()
+	assert.Equal(t, uint64(128), p.defaultSize)
+	assert.Equal(t, uint64(8192*2-1), p.maxSize)
+
+	for i := 0; i < calibrateCalls; i++ {
+		if i%100 == 0 {
+			p.record(8000)
+		} else {
+			p.record(100)
+		}
+	}
+	p.calibrate()
+	// 只占1%的大缓冲区不再放回池中
+	assert.Equal(t, uint64(128*2-1), p.maxSize)
+}
+
+func TestByteBuffer_write(t *testing.T) {
+	p := NewBytePool()
+	b := p.GetBuffer()
+	b.WriteString("hello")
+	b.WriteByte(' ')
+	b.WriteRune('世')
+	b.Write([]byte("界"))
+	assert.Equal(t, "hello 世界", b.String())
+	assert.Equal(t, len("hello 世界"), b.Len())
+
+	b.Truncate(5)
+	assert.Equal(t, []byte("hello"), b.Bytes())
+	b.Reset()
+	assert.Equal(t, 0, b.Len())
+	p.PutBuffer(b)
+}
+
+func TestByteBuffer_Grow(t *testing.T) {
+	p := NewBytePool()
+	b := p.GetBuffer()
+	b.WriteString("abc")
+	b.Grow(1000)
+	assert.GreaterOrEqual(t, b.Cap()-b.Len(), 1000)
+	assert.Equal(t, 1024, b.Cap())
+	assert.Equal(t, "abc", b.String())
+	assert.Panics(t, func() { b.Grow(-1) })
+}
+
+func TestByteBuffer_zero_value(t *testing.T) {
+	var b ByteBuffer
+	s := strings.Repeat("x", 1000)
+	b.WriteString(s)
+	assert.Equal(t, s, b.String())
+}
+
+func TestByteBuffer_ReadFrom_WriteTo(t *testing.T) {
+	b := GetBuffer()
+	defer PutBuffer(b)
+	s := strings.Repeat("0123456789", 1000)
+	n, err := b.ReadFrom(strings.NewReader(s))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(s)), n)
+	assert.Equal(t, s, b.String())
+
+	var out bytes.Buffer
+	n, err = b.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(s)), n)
+	assert.Equal(t, s, out.String())
+}
+
+func TestPutBuffer_reset(t *testing.T) {
+	p := NewBytePool()
+	b := p.GetBuffer()
+	b.WriteString("secret")
+	p.PutBuffer(b)
+	b = p.GetBuffer()
+	assert.Equal(t, 0, b.Len())
+}
+
+func BenchmarkBytePool(b *testing.B) {
+	p := NewBytePool()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := p.Get(1000)
+			buf = append(buf, "hello"...)
+			p.Put(buf)
+		}
+	})
+}
+
+func BenchmarkByteBuffer(b *testing.B) {
+	p := NewBytePool()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := p.GetBuffer()
+			buf.WriteString("hello world")
+			p.PutBuffer(buf)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package syncPool
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+const (
+	// poisonByte 毒化后数值类型的每个字节都是这个值
+	poisonByte = 0xA5
+	// PoisonString 毒化后字符串的值
+	PoisonString = "<poisoned by syncPool>"
+)
+
+// Poison 毒化v指向的值: 数值的每个字节置为0xA5, 布尔值置为true, 字符串置为 PoisonString,
+// 指针、切片、map、chan、函数与接口置为nil, 结构体与数组逐个毒化其中的元素, 包括未导出的字段.
+// v不是非nil的指针时不做任何操作
+func Poison(v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return
+	}
+	poisonValue(rv.Elem())
+}
+
+func poisonValue(v reflect.Value) {
+	if !v.CanSet() {
+		// 未导出的字段不能通过反射直接修改
+		v = reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		b := unsafe.Slice((*byte)(unsafe.Pointer(v.UnsafeAddr())), v.Type().Size())
+		for i := range b {
+			b[i] = poisonByte
+		}
+	case reflect.String:
+		v.SetString(PoisonString)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			poisonValue(v.Field(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			poisonValue(v.Index(i))
+		}
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
@@ -0,0 +1,6 @@
+//go:build syncpooldebug
+
+package syncPool
+
+// debug 使用 syncpooldebug 构建标签时为true, 归还的对象会被毒化并且不再放回池中
+const debug = true
@@ -0,0 +1,31 @@
+//go:build syncpooldebug
+
+package syncPool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_Put_poisons_in_debug_build(t *testing.T) {
+	pool := NewSyncPool[session]()
+	s := pool.Get()
+	s.user = "alice"
+	pool.Put(s)
+	// 归还后仍在使用对象时读到毒化的值
+	assert.Equal(t, PoisonString, s.user)
+	for i := 0; i < 100; i++ {
+		assert.NotSame(t, s, pool.Get())
+	}
+}
+
+func TestPool_WithPoison(t *testing.T) {
+	var poisoned []*session
+	pool := NewPool[*session](WithPoison(func(s *session) {
+		poisoned = append(poisoned, s)
+	}))
+	s := pool.Get()
+	pool.Put(s)
+	assert.Equal(t, []*session{s}, poisoned)
+}
@@ -5,23 +5,142 @@ import (
 	"sync"
 )
 
-// SyncPool 对sync.Pool进行的封装, 使用如果是相同类型, 会使用相同的sync.Pool
+// Resetter 池中的对象可以实现的接口, 对象归还时调用 Reset 清除上一个使用者留下的状态
+type Resetter interface {
+	Reset()
+}
+
+// Option 对象池的配置项
+type Option[T any] func(*options[T])
+
+type options[T any] struct {
+	new    func() T
+	reset  func(T)
+	poison func(T)
+}
+
+// WithNew 设置池中没有对象时创建对象的函数
+func WithNew[T any](fn func() T) Option[T] {
+	return func(o *options[T]) {
+		o.new = fn
+	}
+}
+
+// WithReset 设置对象归还时调用的重置函数, 设置后不再调用对象的 Resetter.Reset
+func WithReset[T any](fn func(T)) Option[T] {
+	return func(o *options[T]) {
+		o.reset = fn
+	}
+}
+
+// WithPoison 设置对象归还后调用的毒化函数, 只在使用 syncpooldebug 构建标签时生效, 默认的毒化函数见 Poison
+func WithPoison[T any](fn func(T)) Option[T] {
+	return func(o *options[T]) {
+		o.poison = fn
+	}
+}
+
+// Pool 对sync.Pool进行的类型安全的封装, T通常为指针类型; T不是指针类型时存取对象会产生额外的内存分配.
+//
+// 对象归还时会先重置; 使用 syncpooldebug 构建标签时, 归还的对象重置后会被毒化并且不再放回池中,
+// 归还后仍在使用对象的代码会读到明显异常的值, 便于发现
+type Pool[T any] struct {
+	pool   sync.Pool
+	reset  func(T)
+	poison func(T)
+}
+
+// NewPool 创建一个新的实例, 未设置 WithNew 时, T为指针类型则创建指向零值的指针, 否则创建零值, T为接口类型时必须设置 WithNew
+func NewPool[T any](opts ...Option[T]) *Pool[T] {
+	o := &options[T]{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.new == nil {
+		o.new = defaultNew[T]()
+	}
+	if o.reset == nil {
+		typeOf := reflect.TypeOf((*T)(nil)).Elem()
+		if typeOf.Kind() == reflect.Interface || typeOf.Implements(resetterType) {
+			o.reset = func(t T) {
+				if r, ok := any(t).(Resetter); ok {
+					r.Reset()
+				}
+			}
+		}
+	}
+	if o.poison == nil {
+		o.poison = func(t T) {
+			Poison(t)
+		}
+	}
+	return &Pool[T]{
+		pool: sync.Pool{
+			New: func() any {
+				return o.new()
+			},
+		},
+		reset:  o.reset,
+		poison: o.poison,
+	}
+}
+
+var resetterType = reflect.TypeOf((*Resetter)(nil)).Elem()
+
+func defaultNew[T any]() func() T {
+	typeOf := reflect.TypeOf((*T)(nil)).Elem()
+	switch typeOf.Kind() {
+	case reflect.Interface:
+		panic("cannot create Pool for interface type without WithNew")
+	case reflect.Ptr:
+		elem := typeOf.Elem()
+		return func() T {
+			return reflect.New(elem).Interface().(T)
+		}
+	default:
+		return func() T {
+			var t T
+			return t
+		}
+	}
+}
+
+// Get 从池中取出一个对象, 没有时创建新的对象
+func (p *Pool[T]) Get() T {
+	return p.pool.Get().(T)
+}
+
+// Put 重置对象后放回池中, 归还后不能再使用t
+func (p *Pool[T]) Put(t T) {
+	if p.reset != nil {
+		p.reset(t)
+	}
+	if debug {
+		p.poison(t)
+		return
+	}
+	p.pool.Put(t)
+}
+
+// SyncPool 对sync.Pool进行的封装, 存取的是T的指针; 不传入配置项创建时如果是相同类型, 会使用相同的sync.Pool
 type SyncPool[T any] struct {
-	pool sync.Pool
+	pool *Pool[*T]
 }
 
 var poolMap = make(map[reflect.Type]any)
 var poolMapLock sync.RWMutex
 
-func NewSyncPool[T any]() *SyncPool[T] {
-	var t T
-	typeOf := reflect.TypeOf(t)
-	if typeOf == nil || typeOf.Kind() == reflect.Interface {
+// NewSyncPool 创建存取*T的对象池, 未设置 WithNew 时使用new(T)创建对象, T为指针类型时还会创建T指向的零值;
+// *T或T实现了 Resetter 时归还对象会调用 Reset.
+//
+// 不传入配置项时返回该类型共用的对象池, 传入配置项时每次都创建新的对象池, 因为不同的创建与重置函数得到的对象不能混用
+func NewSyncPool[T any](opts ...Option[*T]) *SyncPool[T] {
+	typeOf := reflect.TypeOf((*T)(nil)).Elem()
+	if typeOf.Kind() == reflect.Interface {
 		panic("cannot create SyncPool for interface{} or nil type")
 	}
-
-	if typeOf.Kind() == reflect.Ptr {
-		panic("SyncPool does not support pointer types")
+	if len(opts) > 0 {
+		return newSyncPool[T](opts)
 	}
 
 	poolMapLock.RLock()
@@ -36,21 +155,38 @@ func NewSyncPool[T any]() *SyncPool[T] {
 	if ok {
 		return sp.(*SyncPool[T])
 	}
-	s := &SyncPool[T]{
-		pool: sync.Pool{
-			New: func() any {
-				return new(T)
-			},
-		},
-	}
+	s := newSyncPool[T](nil)
 	poolMap[typeOf] = s
 	return s
 }
 
+func newSyncPool[T any](opts []Option[*T]) *SyncPool[T] {
+	var defaults []Option[*T]
+	typeOf := reflect.TypeOf((*T)(nil)).Elem()
+	if typeOf.Kind() == reflect.Ptr {
+		elem := typeOf.Elem()
+		defaults = append(defaults, WithNew(func() *T {
+			t := new(T)
+			reflect.ValueOf(t).Elem().Set(reflect.New(elem))
+			return t
+		}))
+		if typeOf.Implements(resetterType) {
+			defaults = append(defaults, WithReset(func(t *T) {
+				if r, ok := any(*t).(Resetter); ok {
+					r.Reset()
+				}
+			}))
+		}
+	}
+	return &SyncPool[T]{pool: NewPool[*T](append(defaults, opts...)...)}
+}
+
+// Get 从池中取出一个对象, 没有时创建新的对象
 func (s *SyncPool[T]) Get() *T {
-	return s.pool.Get().(*T)
+	return s.pool.Get()
 }
 
+// Put 重置对象后放回池中, 归还后不能再使用t
 func (s *SyncPool[T]) Put(t *T) {
 	s.pool.Put(t)
 }
@@ -1,9 +1,13 @@
 package syncPool
 
 import (
+	"bytes"
 	"fmt"
-	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type node[T any] struct {
@@ -31,6 +35,9 @@ func TestNewSyncPool_not_same_pool_when_Generics_type_is_not_same(t *testing.T)
 }
 
 func TestSyncPool_Get_reuse_object(t *testing.T) {
+	if debug {
+		t.Skip("objects are not reused in debug builds")
+	}
 	syncPool := NewSyncPool[int]()
 	firstGet := syncPool.Get()
 	*firstGet = 4
@@ -41,3 +48,164 @@ func TestSyncPool_Get_reuse_object(t *testing.T) {
 	assert.Equal(t, first, second)
 	assert.Equal(t, *firstGet, *secondGet)
 }
+
+type session struct {
+	user  string
+	items []int
+}
+
+func (s *session) Reset() {
+	s.user = ""
+	s.items = s.items[:0]
+}
+
+// reuse 归还后再次获取, 直到得到同一个对象.
+// 开启race检测时sync.Pool会随机丢弃对象, 因此重复多次
+func reuse[T comparable](get func() T, put func(T), obj T) bool {
+	for i := 0; i < 100; i++ {
+		put(obj)
+		if get() == obj {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSyncPool_Put_calls_Resetter(t *testing.T) {
+	if debug {
+		t.Skip("objects are not reused in debug builds")
+	}
+	pool := NewSyncPool[session]()
+	s := pool.Get()
+	s.user = "alice"
+	s.items = append(s.items, 1, 2)
+	assert.True(t, reuse(pool.Get, pool.Put, s))
+	assert.Equal(t, "", s.user)
+	assert.Equal(t, 0, len(s.items))
+	assert.Equal(t, 2, cap(s.items))
+}
+
+func TestNewSyncPool_with_options_creates_separate_pool(t *testing.T) {
+	var created int
+	newNode := func() *node[string] {
+		created++
+		return &node[string]{val: "init"}
+	}
+	pool1 := NewSyncPool[node[string]](WithNew(newNode))
+	pool2 := NewSyncPool[node[string]](WithNew(newNode))
+	assert.NotSame(t, pool1, pool2)
+	assert.NotSame(t, NewSyncPool[node[string]](), pool1)
+
+	assert.Equal(t, "init", pool1.Get().val)
+	assert.Equal(t, 1, created)
+}
+
+func TestNewSyncPool_WithReset(t *testing.T) {
+	if debug {
+		t.Skip("objects are not reused in debug builds")
+	}
+	var resets int
+	pool := NewSyncPool[session](WithReset(func(s *session) {
+		resets++
+		s.user = "reset"
+	}))
+	s := pool.Get()
+	s.user = "alice"
+	s.items = []int{1}
+	assert.True(t, reuse(pool.Get, pool.Put, s))
+	// 设置了重置函数后不再调用 Reset
+	assert.Equal(t, "reset", s.user)
+	assert.Equal(t, []int{1}, s.items)
+	assert.GreaterOrEqual(t, resets, 1)
+}
+
+func TestNewSyncPool_pointer_type(t *testing.T) {
+	pool := NewSyncPool[*session]()
+	assert.Same(t, pool, NewSyncPool[*session]())
+	s := pool.Get()
+	assert.NotNil(t, *s)
+	inner := *s
+	inner.user = "alice"
+	pool.Put(s)
+	// T实现了 Resetter 时同样会被重置
+	assert.Equal(t, "", inner.user)
+}
+
+func TestNewSyncPool_interface_type(t *testing.T) {
+	assert.Panics(t, func() { NewSyncPool[fmt.Stringer]() })
+	assert.Panics(t, func() { NewSyncPool[any]() })
+}
+
+func TestPool(t *testing.T) {
+	if debug {
+		t.Skip("objects are poisoned after Put in debug builds")
+	}
+	pool := NewPool[*bytes.Buffer]()
+	buf := pool.Get()
+	assert.NotNil(t, buf)
+	buf.WriteString("secret")
+	pool.Put(buf)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestPool_value_type(t *testing.T) {
+	pool := NewPool[int](WithNew(func() int { return 7 }))
+	assert.Equal(t, 7, pool.Get())
+	assert.Equal(t, 0, NewPool[int]().Get())
+}
+
+func TestPool_interface_type(t *testing.T) {
+	if debug {
+		t.Skip("objects are poisoned after Put in debug builds")
+	}
+	assert.Panics(t, func() { NewPool[io.Reader]() })
+
+	pool := NewPool[io.Writer](WithNew(func() io.Writer { return &bytes.Buffer{} }))
+	w := pool.Get()
+	w.Write([]byte("secret"))
+	pool.Put(w)
+	// 接口的动态类型实现了 Resetter 时同样会被重置
+	assert.Equal(t, 0, w.(*bytes.Buffer).Len())
+}
+
+type poisoned struct {
+	ok     bool
+	n      int32
+	f      float64
+	s      string
+	p      *int
+	items  []int
+	m      map[string]int
+	arr    [2]uint8
+	nested struct{ Name string }
+}
+
+func TestPoison(t *testing.T) {
+	n := 1
+	v := &poisoned{
+		n:      1,
+		f:      1,
+		s:      "s",
+		p:      &n,
+		items:  []int{1},
+		m:      map[string]int{},
+		arr:    [2]uint8{1, 2},
+		nested: struct{ Name string }{"name"},
+	}
+	Poison(v)
+	assert.True(t, v.ok)
+	assert.Equal(t, int32(-0x5a5a5a5b), v.n)
+	assert.True(t, math.IsNaN(v.f) || v.f != 1)
+	assert.Equal(t, PoisonString, v.s)
+	assert.Nil(t, v.p)
+	assert.Nil(t, v.items)
+	assert.Nil(t, v.m)
+	assert.Equal(t, [2]uint8{0xA5, 0xA5}, v.arr)
+	assert.Equal(t, PoisonString, v.nested.Name)
+
+	assert.NotPanics(t, func() {
+		Poison(nil)
+		Poison(1)
+		Poison((*poisoned)(nil))
+	})
+}
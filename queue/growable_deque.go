@@ -0,0 +1,144 @@
+package queue
+
+// GrowableDeque 基于数组的双向循环队列，队列满时自动扩容为原来的两倍
+type GrowableDeque[T any] struct {
+	data  []T
+	front int // 队头指针（指向第一个元素）
+	size  int // 当前元素个数
+}
+
+// NewGrowableDeque 创建一个新的可扩容双向循环队列, capacity为初始容量
+func NewGrowableDeque[T any](capacity int) *GrowableDeque[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &GrowableDeque[T]{
+		data: make([]T, capacity),
+	}
+}
+
+// IsEmpty 是否为空
+func (d *GrowableDeque[T]) IsEmpty() bool {
+	return d.size == 0
+}
+
+// IsFull 队列会自动扩容, 总是返回false
+func (d *GrowableDeque[T]) IsFull() bool {
+	return false
+}
+
+// Size 获取当前元素数量
+func (d *GrowableDeque[T]) Size() int {
+	return d.size
+}
+
+// Capacity 获取当前底层数组的容量
+func (d *GrowableDeque[T]) Capacity() int {
+	return len(d.data)
+}
+
+// PushFront 在队头插入元素
+func (d *GrowableDeque[T]) PushFront(item T) {
+	d.grow()
+	d.front = (d.front - 1 + len(d.data)) % len(d.data)
+	d.data[d.front] = item
+	d.size++
+}
+
+// PushBack 在队尾插入元素
+func (d *GrowableDeque[T]) PushBack(item T) {
+	d.grow()
+	d.data[d.index(d.size)] = item
+	d.size++
+}
+
+// PopFront 删除并返回队头元素
+func (d *GrowableDeque[T]) PopFront() (T, bool) {
+	var zero T
+	if d.IsEmpty() {
+		return zero, false
+	}
+	item := d.data[d.front]
+	// 清除引用, 避免已出队的元素无法被回收
+	d.data[d.front] = zero
+	d.front = (d.front + 1) % len(d.data)
+	d.size--
+	return item, true
+}
+
+// PopBack 删除并返回队尾元素
+func (d *GrowableDeque[T]) PopBack() (T, bool) {
+	var zero T
+	if d.IsEmpty() {
+		return zero, false
+	}
+	i := d.index(d.size - 1)
+	item := d.data[i]
+	d.data[i] = zero
+	d.size--
+	return item, true
+}
+
+// Front 获取队头元素
+func (d *GrowableDeque[T]) Front() (T, bool) {
+	var zero T
+	if d.IsEmpty() {
+		return zero, false
+	}
+	return d.data[d.front], true
+}
+
+// Back 获取队尾元素
+func (d *GrowableDeque[T]) Back() (T, bool) {
+	var zero T
+	if d.IsEmpty() {
+		return zero, false
+	}
+	return d.data[d.index(d.size-1)], true
+}
+
+// Range 遍历所有元素，按从队头到队尾的顺序执行函数 fn
+func (d *GrowableDeque[T]) Range(fn func(T) bool) {
+	for i := 0; i < d.size; i++ {
+		if !fn(d.data[d.index(i)]) {
+			return
+		}
+	}
+}
+
+// ReverseRange 遍历队列，按逆序执行函数 fn
+func (d *GrowableDeque[T]) ReverseRange(fn func(item T) bool) {
+	for i := d.size - 1; i >= 0; i-- {
+		if !fn(d.data[d.index(i)]) {
+			return
+		}
+	}
+}
+
+// Clear 清空队列
+func (d *GrowableDeque[T]) Clear() {
+	var zero T
+	for i := 0; i < d.size; i++ {
+		d.data[d.index(i)] = zero
+	}
+	d.front = 0
+	d.size = 0
+}
+
+// index 第i个元素在底层数组中的下标
+func (d *GrowableDeque[T]) index(i int) int {
+	return (d.front + i) % len(d.data)
+}
+
+// grow 队列已满时扩容
+func (d *GrowableDeque[T]) grow() {
+	if d.size < len(d.data) {
+		return
+	}
+	data := make([]T, len(d.data)*2)
+	for i := 0; i < d.size; i++ {
+		data[i] = d.data[d.index(i)]
+	}
+	d.data = data
+	d.front = 0
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var _ Deque[int] = (*GrowableDeque[int])(nil)
+
+func collect(d *GrowableDeque[int]) []int {
+	var items []int
+	d.Range(func(item int) bool {
+		items = append(items, item)
+		return true
+	})
+	return items
+}
+
+func TestGrowableDeque_grow(t *testing.T) {
+	deque := NewGrowableDeque[int](2)
+	deque.PushBack(2)
+	deque.PushFront(1)
+	deque.PushBack(3)
+	deque.PushFront(0)
+	deque.PushBack(4)
+
+	assert.Equal(t, 5, deque.Size())
+	assert.Equal(t, 8, deque.Capacity())
+	assert.False(t, deque.IsFull())
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, collect(deque))
+}
+
+func TestGrowableDeque_pop(t *testing.T) {
+	deque := NewGrowableDeque[int](4)
+	for i := 0; i < 6; i++ {
+		deque.PushBack(i)
+	}
+	front, ok := deque.PopFront()
+	assert.True(t, ok)
+	assert.Equal(t, 0, front)
+	back, ok := deque.PopBack()
+	assert.True(t, ok)
+	assert.Equal(t, 5, back)
+
+	front, _ = deque.Front()
+	back, _ = deque.Back()
+	assert.Equal(t, 1, front)
+	assert.Equal(t, 4, back)
+
+	for i := 0; i < 4; i++ {
+		deque.PopBack()
+	}
+	assert.True(t, deque.IsEmpty())
+	_, ok = deque.PopFront()
+	assert.False(t, ok)
+	_, ok = deque.PopBack()
+	assert.False(t, ok)
+}
+
+func TestGrowableDeque_wrap_around(t *testing.T) {
+	deque := NewGrowableDeque[int](4)
+	for i := 0; i < 100; i++ {
+		deque.PushBack(i)
+		deque.PushBack(i)
+		deque.PopFront()
+	}
+	assert.Equal(t, 100, deque.Size())
+	items := collect(deque)
+	assert.Equal(t, 50, items[0])
+	assert.Equal(t, 99, items[99])
+
+	var reversed []int
+	deque.ReverseRange(func(item int) bool {
+		reversed = append(reversed, item)
+		return len(reversed) < 3
+	})
+	assert.Equal(t, []int{99, 99, 98}, reversed)
+}
+
+func TestGrowableDeque_Clear(t *testing.T) {
+	deque := NewGrowableDeque[int](0)
+	deque.PushBack(1)
+	deque.PushBack(2)
+	deque.Clear()
+	assert.True(t, deque.IsEmpty())
+	assert.Nil(t, collect(deque))
+	deque.PushFront(3)
+	assert.Equal(t, []int{3}, collect(deque))
+}